@@ -0,0 +1,216 @@
+// Tideland Go Text - Dynamic JSON
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package dj // import "tideland.dev/go/text/dj"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"reflect"
+)
+
+//--------------------
+// AGGREGATION
+//--------------------
+
+// AggregateFunc describes a function aggregating a value, e.g.
+// the group of a GroupBy(), into one result value.
+type AggregateFunc func(v *Value) *Value
+
+// Count returns the number of elements of an array value.
+func (v *Value) Count() *Value {
+	elements, err := v.elements()
+	if err != nil {
+		return newValue(v.path, nil, err)
+	}
+	return newValue(v.path, len(elements), nil)
+}
+
+// Sum returns the sum of the numbers at the given path of all
+// array elements. Numbers are converted like by AsFloat64(),
+// elements without a number at the path are skipped.
+func (v *Value) Sum(path ...string) *Value {
+	numbers, err := v.numbers(path)
+	if err != nil {
+		return newValue(v.path, nil, err)
+	}
+	sum := 0.0
+	for _, n := range numbers {
+		sum += n
+	}
+	return newValue(v.path, sum, nil)
+}
+
+// Min returns the minimum of the numbers at the given path of all
+// array elements. It is undefined if there are no numbers.
+func (v *Value) Min(path ...string) *Value {
+	numbers, err := v.numbers(path)
+	if err != nil || len(numbers) == 0 {
+		return newValue(v.path, nil, err)
+	}
+	min := numbers[0]
+	for _, n := range numbers[1:] {
+		if n < min {
+			min = n
+		}
+	}
+	return newValue(v.path, min, nil)
+}
+
+// Max returns the maximum of the numbers at the given path of all
+// array elements. It is undefined if there are no numbers.
+func (v *Value) Max(path ...string) *Value {
+	numbers, err := v.numbers(path)
+	if err != nil || len(numbers) == 0 {
+		return newValue(v.path, nil, err)
+	}
+	max := numbers[0]
+	for _, n := range numbers[1:] {
+		if n > max {
+			max = n
+		}
+	}
+	return newValue(v.path, max, nil)
+}
+
+// Average returns the average of the numbers at the given path of all
+// array elements. It is undefined if there are no numbers.
+func (v *Value) Average(path ...string) *Value {
+	numbers, err := v.numbers(path)
+	if err != nil || len(numbers) == 0 {
+		return newValue(v.path, nil, err)
+	}
+	sum := 0.0
+	for _, n := range numbers {
+		sum += n
+	}
+	return newValue(v.path, sum/float64(len(numbers)), nil)
+}
+
+// Distinct returns an array of the distinct values at the given path
+// of all array elements in the order of their first occurrence.
+func (v *Value) Distinct(path ...string) *Value {
+	elements, err := v.elements()
+	if err != nil {
+		return newValue(v.path, nil, err)
+	}
+	distinct := []interface{}{}
+	for _, element := range elements {
+		data, err := nodeAt(element, []string{}, path)
+		if err != nil {
+			continue
+		}
+		found := false
+		for _, d := range distinct {
+			if reflect.DeepEqual(d, data) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			distinct = append(distinct, data)
+		}
+	}
+	return newValue(v.path, distinct, nil)
+}
+
+// GroupBy returns an object containing the array elements grouped by
+// the string representation of their value at the given path. Elements
+// without a value at the path are skipped.
+func (v *Value) GroupBy(path ...string) *Value {
+	elements, err := v.elements()
+	if err != nil {
+		return newValue(v.path, nil, err)
+	}
+	groups := map[string]interface{}{}
+	for _, element := range elements {
+		data, err := nodeAt(element, []string{}, path)
+		if err != nil || data == nil {
+			continue
+		}
+		key := newValue(nil, data, nil).AsString("")
+		group, _ := groups[key].([]interface{})
+		groups[key] = append(group, element)
+	}
+	return newValue(v.path, groups, nil)
+}
+
+// Aggregate applies the aggregate function to all elements of an object
+// or array value, e.g. the groups returned by GroupBy(), and returns the
+// results in a new object or array.
+func (v *Value) Aggregate(f AggregateFunc) *Value {
+	if v.err != nil {
+		return newValue(v.path, nil, v.err)
+	}
+	switch d := v.data.(type) {
+	case map[string]interface{}:
+		results := make(map[string]interface{}, len(d))
+		for k, data := range d {
+			result := f(newValue(appendPath(v.path, k), data, nil))
+			if result.err != nil {
+				return newValue(v.path, nil, result.err)
+			}
+			results[k] = result.data
+		}
+		return newValue(v.path, results, nil)
+	case []interface{}:
+		results := make([]interface{}, len(d))
+		for i, data := range d {
+			result := f(newValue(v.path, data, nil))
+			if result.err != nil {
+				return newValue(v.path, nil, result.err)
+			}
+			results[i] = result.data
+		}
+		return newValue(v.path, results, nil)
+	}
+	return newValue(v.path, nil, &ValueError{
+		Mode: "aggregate",
+		Path: v.path,
+		Err:  fmt.Errorf("%w: no object or array", ErrInvalidType),
+	})
+}
+
+// elements returns the elements of an array value.
+func (v *Value) elements() ([]interface{}, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	elements, ok := v.data.([]interface{})
+	if !ok {
+		return nil, &ValueError{
+			Mode: "aggregate",
+			Path: v.path,
+			Err:  fmt.Errorf("%w: no array", ErrInvalidType),
+		}
+	}
+	return elements, nil
+}
+
+// numbers returns the numbers at the path of all array elements.
+func (v *Value) numbers(path []string) ([]float64, error) {
+	elements, err := v.elements()
+	if err != nil {
+		return nil, err
+	}
+	numbers := []float64{}
+	for _, element := range elements {
+		data, err := nodeAt(element, []string{}, path)
+		if err != nil {
+			continue
+		}
+		if n, ok := asFloat64(data); ok {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers, nil
+}
+
+// EOF
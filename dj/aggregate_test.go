@@ -0,0 +1,118 @@
+// Tideland Go Text - Dynamic JSON - Testing
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package dj_test // import "tideland.dev/go/text/dj"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/text/dj"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+const aggregateDocument = `{
+	"orders": [
+		{"id": 1, "total": 10.5, "currency": "EUR"},
+		{"id": 2, "total": "20", "currency": "USD"},
+		{"id": 3, "total": 5, "currency": "EUR"},
+		{"id": 4, "currency": "USD"},
+		{"id": 5, "total": 2.5, "currency": "GBP"}
+	],
+	"numbers": [3, 1, 4, 1, 5, 9, 2, 6],
+	"empty": [],
+	"name": "test"
+}`
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestValueAggregates verifies the simple aggregate functions.
+func TestValueAggregates(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	doc, err := dj.Parse(bytes.NewBufferString(aggregateDocument))
+	assert.NoError(err)
+
+	orders := doc.At("orders")
+	assert.Equal(orders.Count().AsInt(0), 5)
+	assert.Equal(orders.Sum("total").AsFloat64(0.0), 38.0)
+	assert.Equal(orders.Min("total").AsFloat64(0.0), 2.5)
+	assert.Equal(orders.Max("total").AsFloat64(0.0), 20.0)
+	assert.Equal(orders.Average("total").AsFloat64(0.0), 9.5)
+	assert.Equal(orders.Sum("unknown").AsFloat64(-1.0), 0.0)
+	assert.True(orders.Average("unknown").IsUndefined())
+
+	numbers := doc.At("numbers")
+	assert.Equal(numbers.Sum().AsInt(0), 31)
+	assert.Equal(numbers.Min().AsInt(0), 1)
+	assert.Equal(numbers.Max().AsInt(0), 9)
+	assert.Equal(numbers.Distinct().Len(), 7)
+	assert.Equal(numbers.Distinct().At("#3").AsInt(0), 5)
+
+	currencies := orders.Distinct("currency")
+	assert.Equal(currencies.Type(), dj.NodeTypeArray)
+	assert.Length(currencies, 3)
+	assert.Equal(currencies.At("#2").AsString(""), "GBP")
+
+	empty := doc.At("empty")
+	assert.Equal(empty.Count().AsInt(-1), 0)
+	assert.Equal(empty.Sum().AsFloat64(-1.0), 0.0)
+	assert.True(empty.Min().IsUndefined())
+	assert.True(empty.Max().IsUndefined())
+	assert.True(empty.Average().IsUndefined())
+
+	// Errors.
+	assert.ErrorContains(doc.At("name").Count().Error(), "no array")
+	assert.ErrorContains(doc.At("name").Sum().Error(), "no array")
+	assert.ErrorContains(doc.At("unknown").Max().Error(), "path does not exist")
+}
+
+// TestValueGroupBy verifies grouping and aggregating of groups.
+func TestValueGroupBy(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	doc, err := dj.Parse(bytes.NewBufferString(aggregateDocument))
+	assert.NoError(err)
+
+	groups := doc.At("orders").GroupBy("currency")
+	assert.Equal(groups.Type(), dj.NodeTypeObject)
+	assert.Length(groups, 3)
+	assert.Length(groups.At("EUR"), 2)
+	assert.Equal(groups.At("USD", "#1", "id").AsInt(0), 4)
+
+	sums := groups.Aggregate(func(g *dj.Value) *dj.Value {
+		return g.Sum("total")
+	})
+	assert.NoError(sums.Error())
+	assert.Equal(sums.At("EUR").AsFloat64(0.0), 15.5)
+	assert.Equal(sums.At("USD").AsFloat64(0.0), 20.0)
+	assert.Equal(sums.At("GBP").AsFloat64(0.0), 2.5)
+
+	counts := groups.Aggregate(func(g *dj.Value) *dj.Value {
+		return g.Count()
+	})
+	assert.Equal(counts.At("EUR").AsInt(0), 2)
+
+	// Errors.
+	assert.ErrorContains(doc.At("name").GroupBy("x").Error(), "no array")
+	assert.ErrorContains(doc.At("name").Aggregate(func(g *dj.Value) *dj.Value {
+		return g
+	}).Error(), "no object or array")
+	assert.ErrorContains(groups.Aggregate(func(g *dj.Value) *dj.Value {
+		return g.At("total").Sum()
+	}).Error(), "no index")
+}
+
+// EOF
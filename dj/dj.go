@@ -62,10 +62,38 @@ func (d *Document) At(path ...string) *Value {
 	return newValue(path, data, nil)
 }
 
+// SetAt sets the data at a given path of keys. Missing objects on
+// the way are created, array elements can be appended using "#+"
+// or "#append". The data may be nil or one of string, int, float64,
+// bool, map[string]interface{}, or []interface{}, also nested maps
+// and slices may only contain these types. The data is copied, so
+// later changes by the caller don't affect the document.
+func (d *Document) SetAt(data interface{}, path ...string) error {
+	data, ok := nodeValidCopy(data)
+	if !ok {
+		return &PathError{
+			Mode: "set",
+			Path: path,
+			Err:  ErrInvalidType,
+		}
+	}
+	root, err := nodeSetAt(d.root, []string{}, path, data)
+	if err != nil {
+		return err
+	}
+	d.root = root
+	return nil
+}
+
 // Root is a convenience varient of At() for the highest
 // level value.
 func (d *Document) Root() *Value {
 	return newValue([]string{}, d.root, nil)
 }
 
+// MarshalJSON implements json.Marshaler.
+func (d *Document) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.root)
+}
+
 // EOF
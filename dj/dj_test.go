@@ -13,6 +13,7 @@ package dj_test // import "tideland.dev/go/text/dj"
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 
 	"tideland.dev/go/audit/asserts"
@@ -164,6 +165,48 @@ func TestDocumentAt(t *testing.T) {
 			[]string{"o", "a", "#999"},
 			"",
 			"invalid array index",
+		}, {
+			"invalid index / empty",
+			`{"s": "string","o":{"x":"foo","a":["1","2","3","4","5"]}}`,
+			[]string{"o", "a", ""},
+			"",
+			"no index",
+		}, {
+			"negative index",
+			`{"s": "string","o":{"x":"foo","a":["1","2","3","4","5"]}}`,
+			[]string{"o", "a", "#-1"},
+			"5",
+			"",
+		}, {
+			"invalid negative index",
+			`{"s": "string","o":{"x":"foo","a":["1","2","3","4","5"]}}`,
+			[]string{"o", "a", "#-6"},
+			"",
+			"invalid array index",
+		}, {
+			"append index",
+			`{"s": "string","o":{"x":"foo","a":["1","2","3","4","5"]}}`,
+			[]string{"o", "a", "#+"},
+			"",
+			"invalid array index",
+		}, {
+			"slice",
+			`{"s": "string","o":{"x":"foo","a":["1","2","3","4","5"]}}`,
+			[]string{"o", "a", "#1:3", "#-1"},
+			"3",
+			"",
+		}, {
+			"open slice",
+			`{"s": "string","o":{"x":"foo","a":["1","2","3","4","5"]}}`,
+			[]string{"o", "a", "#-2:", "#0"},
+			"4",
+			"",
+		}, {
+			"invalid slice",
+			`{"s": "string","o":{"x":"foo","a":["1","2","3","4","5"]}}`,
+			[]string{"o", "a", "#1:x"},
+			"",
+			"no index",
 		},
 	}
 	for _, test := range tests {
@@ -183,6 +226,164 @@ func TestDocumentAt(t *testing.T) {
 	}
 }
 
+// TestDocumentSlices verifies the length of array slices.
+func TestDocumentSlices(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	doc, err := dj.Parse(bytes.NewBufferString(`{"a":[0,1,2,3,4,5,6,7,8,9]}`))
+	assert.NoError(err)
+	tests := []struct {
+		index  string
+		length int
+		first  int
+	}{
+		{"#2:5", 3, 2},
+		{"#:3", 3, 0},
+		{"#7:", 3, 7},
+		{"#-3:-1", 2, 7},
+		{"#5:100", 5, 5},
+		{"#-100:2", 2, 0},
+		{"#5:2", 0, -1},
+		{"#:", 10, 0},
+	}
+	for _, test := range tests {
+		v := doc.At("a", test.index)
+		assert.NoError(v.Error(), test.index)
+		assert.Equal(v.Type(), dj.NodeTypeArray, test.index)
+		assert.Length(v, test.length, test.index)
+		assert.Equal(v.At("#0").AsInt(-1), test.first, test.index)
+	}
+	// Original array stays untouched.
+	assert.Length(doc.At("a"), 10)
+}
+
+// TestDocumentSetAt verifies setting values at paths.
+func TestDocumentSetAt(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	doc := dj.New()
+	assert.NoError(doc.SetAt("foo", "a", "b"))
+	assert.NoError(doc.SetAt(1, "a", "c", "#+"))
+	assert.NoError(doc.SetAt(2, "a", "c", "#append"))
+	assert.NoError(doc.SetAt(3, "a", "c", "#+"))
+	assert.NoError(doc.SetAt(true, "a", "c", "#-1"))
+	assert.NoError(doc.SetAt("x", "a", "d", "#+", "x"))
+	assert.NoError(doc.SetAt(map[string]interface{}{"y": 1}, "a", "d", "#+"))
+
+	assert.Equal(doc.At("a", "b").AsString(""), "foo")
+	assert.Length(doc.At("a", "c"), 3)
+	assert.Equal(doc.At("a", "c", "#0").AsInt(0), 1)
+	assert.Equal(doc.At("a", "c", "#2").AsBool(false), true)
+	assert.Equal(doc.At("a", "d", "#0", "x").AsString(""), "x")
+	assert.Equal(doc.At("a", "d", "#-1", "y").AsInt(0), 1)
+
+	// Errors.
+	err := doc.SetAt(1, "a", "c", "#5")
+	assert.True(errors.Is(err, dj.ErrIndexOutOfRange))
+	err = doc.SetAt(1, "a", "c", "#0:1")
+	assert.True(errors.Is(err, dj.ErrNotAnIndex))
+	err = doc.SetAt(1, "a", "c", "x")
+	assert.True(errors.Is(err, dj.ErrNotAnIndex))
+	err = doc.SetAt(1, "a", "b", "x")
+	assert.True(errors.Is(err, dj.ErrPathTooLong))
+	err = doc.SetAt(struct{}{}, "a", "e")
+	assert.True(errors.Is(err, dj.ErrInvalidType))
+	err = doc.SetAt(map[string]interface{}{"x": []interface{}{int64(1)}}, "a", "e")
+	assert.True(errors.Is(err, dj.ErrInvalidType))
+	err = doc.SetAt([]interface{}{struct{}{}}, "a", "e")
+	assert.True(errors.Is(err, dj.ErrInvalidType))
+	assert.Length(doc.At("a"), 3)
+
+	// Set data is copied.
+	data := map[string]interface{}{"x": []interface{}{1, "two"}}
+	assert.NoError(doc.SetAt(data, "f"))
+	data["x"].([]interface{})[0] = 99
+	data["y"] = true
+	assert.Equal(doc.At("f", "x", "#0").AsInt(0), 1)
+	assert.True(doc.At("f", "y").IsError())
+
+	// Replace the root.
+	assert.NoError(doc.SetAt("root"))
+	assert.Equal(doc.Root().AsString(""), "root")
+}
+
+// TestPathErrors verifies the typed errors when navigating to values.
+func TestPathErrors(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	in := `{"s": "string","o":{"x":"foo","a":["1","2","3","4","5"]}}`
+	tests := []struct {
+		name string
+		path []string
+		err  error
+		at   []string
+	}{
+		{
+			"not existing path",
+			[]string{"o", "oops", "a"},
+			dj.ErrPathNotFound,
+			[]string{"o", "oops"},
+		}, {
+			"path too long",
+			[]string{"o", "x", "oops", "more"},
+			dj.ErrPathTooLong,
+			[]string{"o", "x", "oops", "more"},
+		}, {
+			"no index",
+			[]string{"o", "a", "oops"},
+			dj.ErrNotAnIndex,
+			[]string{"o", "a", "oops"},
+		}, {
+			"invalid index number",
+			[]string{"o", "a", "#x"},
+			dj.ErrNotAnIndex,
+			[]string{"o", "a", "#x"},
+		}, {
+			"index out of range",
+			[]string{"o", "a", "#999"},
+			dj.ErrIndexOutOfRange,
+			[]string{"o", "a", "#999"},
+		},
+	}
+	doc, err := dj.Parse(bytes.NewBufferString(in))
+	assert.NoError(err)
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			defer assert.SetFailable(t)()
+			err := doc.At(test.path...).Error()
+			assert.True(errors.Is(err, test.err))
+			var pe *dj.PathError
+			assert.True(errors.As(err, &pe))
+			assert.Equal(pe.Path, test.at)
+			// Same errors with full paths when starting at a value.
+			err = doc.At(test.path[0]).At(test.path[1:]...).Error()
+			assert.True(errors.Is(err, test.err))
+			assert.True(errors.As(err, &pe))
+			assert.Equal(pe.Path, test.at)
+		})
+	}
+	// Setting invalid types.
+	v := doc.At("o", "x")
+	v.Set(struct{}{})
+	err = v.Error()
+	assert.True(errors.Is(err, dj.ErrInvalidType))
+	var pe *dj.PathError
+	assert.True(errors.As(err, &pe))
+	assert.Equal(pe.Path, []string{"o", "x"})
+}
+
+// TestMarshalJSON verifies the marshalling of a document.
+func TestMarshalJSON(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	in := `{"a":[1,"two",true,null],"b":{"c":1.5}}`
+	doc, err := dj.Parse(bytes.NewBufferString(in))
+	assert.NoError(err)
+	out, err := doc.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(string(out), in)
+	out, err = dj.New().MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(string(out), "null")
+}
+
 // TestDocumentRoot verifies the access to the root value of a document.
 func TestDocumentRoot(t *testing.T) {
 	assert := asserts.NewTesting(t, asserts.FailStop)
@@ -18,6 +18,12 @@
 // The value passed to AsString() will panic if an access does not match (the
 // hard way) or return the default value for the type if the value is nil. And
 // there are methods to set values.
+//
+// Array elements are addressed by "#n", where negative indices like "#-1"
+// count from the end. "#n:m" returns a slice of an array, and "#+" or
+// "#append" appends a new element when setting a value.
+//
+//     err := myCustomer.SetAt("Main Street", "addresses", "#+", "street")
 package dj // import "tideland.dev/go/text/dj"
 
 // EOF
@@ -12,6 +12,7 @@ package dj // import "tideland.dev/go/text/dj"
 //--------------------
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -19,6 +20,24 @@ import (
 // ERRORS
 //--------------------
 
+// Sentinel errors wrapped by the PathError, use them with errors.Is().
+var (
+	// ErrPathNotFound signals that a key of a path does not exist.
+	ErrPathNotFound = errors.New("path does not exist")
+
+	// ErrIndexOutOfRange signals that an array index is out of range.
+	ErrIndexOutOfRange = errors.New("invalid array index")
+
+	// ErrNotAnIndex signals that a key for an array is no valid index.
+	ErrNotAnIndex = errors.New("no index")
+
+	// ErrPathTooLong signals that a path continues after a simple value.
+	ErrPathTooLong = errors.New("path too long")
+
+	// ErrInvalidType signals that data has an invalid type for an operation.
+	ErrInvalidType = errors.New("invalid type")
+)
+
 // DocumentError records an error on higher document level.
 type DocumentError struct {
 	Action string
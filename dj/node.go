@@ -12,8 +12,12 @@ package dj // import "tideland.dev/go/text/dj"
 //--------------------
 
 import (
-	"errors"
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+
+	"tideland.dev/go/text/stringex"
 )
 
 //--------------------
@@ -115,51 +119,292 @@ func nodeAt(data interface{}, done, path []string) (interface{}, error) {
 		if !ok {
 			return nil, &PathError{
 				Mode: "object",
-				Path: append(done, path[0]),
-				Err:  errors.New("path does not exist"),
+				Path: appendPath(done, path[0]),
+				Err:  ErrPathNotFound,
 			}
 		}
 		if len(path) > 1 {
-			return nodeAt(value, append(done, path[0]), path[1:])
+			return nodeAt(value, appendPath(done, path[0]), path[1:])
 		}
 		return value, nil
 	case []interface{}:
-		index, err := indexOf(path[0])
+		index, err := indexOf(path[0], len(d))
 		if err != nil {
 			return nil, &PathError{
 				Mode: "array",
-				Path: append(done, path[0]),
+				Path: appendPath(done, path[0]),
 				Err:  err,
 			}
 		}
-		if index < 0 || index > len(d)-1 {
+		var value interface{}
+		switch index.kind {
+		case indexSlice:
+			value = append([]interface{}{}, d[index.from:index.to]...)
+		case indexSingle:
+			if index.from >= 0 && index.from < len(d) {
+				value = d[index.from]
+				break
+			}
+			fallthrough
+		default:
 			return nil, &PathError{
 				Mode: "array",
-				Path: append(done, path[0]),
-				Err:  errors.New("invalid array index"),
+				Path: appendPath(done, path[0]),
+				Err:  ErrIndexOutOfRange,
 			}
 		}
-		value := d[index]
 		if len(path) > 1 {
-			return nodeAt(value, append(done, path[0]), path[1:])
+			return nodeAt(value, appendPath(done, path[0]), path[1:])
 		}
 		return value, nil
 	default:
 		return nil, &PathError{
 			Mode: "value",
-			Path: append(done, path...),
-			Err:  errors.New("path too long"),
+			Path: append(appendPath(done, path[0]), path[1:]...),
+			Err:  ErrPathTooLong,
 		}
 	}
 }
 
-// indexOf tries to convert an index string like "#5" into an integer
-// index like 5.
-func indexOf(index string) (int, error) {
-	if index[0] != '#' {
-		return 0, errors.New("no index")
+// nodeSetAt sets the value at the given path of keys and returns the
+// changed node. Missing objects are created on the way, array elements
+// can be appended with "#+" or "#append".
+func nodeSetAt(data interface{}, done, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	switch d := data.(type) {
+	case nil:
+		if path[0] == "" || path[0][0] != '#' {
+			return nodeSetAt(map[string]interface{}{}, done, path, value)
+		}
+		return nodeSetAt([]interface{}{}, done, path, value)
+	case map[string]interface{}:
+		sub, err := nodeSetAt(d[path[0]], appendPath(done, path[0]), path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		d[path[0]] = sub
+		return d, nil
+	case []interface{}:
+		index, err := indexOf(path[0], len(d))
+		if err != nil {
+			return nil, &PathError{
+				Mode: "set",
+				Path: appendPath(done, path[0]),
+				Err:  err,
+			}
+		}
+		switch {
+		case index.kind == indexAppend:
+			sub, err := nodeSetAt(nil, appendPath(done, path[0]), path[1:], value)
+			if err != nil {
+				return nil, err
+			}
+			return append(d, sub), nil
+		case index.kind == indexSingle && index.from >= 0 && index.from < len(d):
+			sub, err := nodeSetAt(d[index.from], appendPath(done, path[0]), path[1:], value)
+			if err != nil {
+				return nil, err
+			}
+			d[index.from] = sub
+			return d, nil
+		case index.kind == indexSlice:
+			return nil, &PathError{
+				Mode: "set",
+				Path: appendPath(done, path[0]),
+				Err:  fmt.Errorf("%w: cannot set slice", ErrNotAnIndex),
+			}
+		}
+		return nil, &PathError{
+			Mode: "set",
+			Path: appendPath(done, path[0]),
+			Err:  ErrIndexOutOfRange,
+		}
+	default:
+		return nil, &PathError{
+			Mode: "set",
+			Path: append(appendPath(done, path[0]), path[1:]...),
+			Err:  ErrPathTooLong,
+		}
+	}
+}
+
+// Kinds of array indices.
+const (
+	indexSingle = iota
+	indexAppend
+	indexSlice
+)
+
+// arrayIndex describes a parsed array index. In case of a slice
+// from and to are the bounds, otherwise from is the index.
+type arrayIndex struct {
+	kind int
+	from int
+	to   int
+}
+
+// indexOf tries to convert an index string for an array of the given
+// length into an array index. Valid are "#5", "#-1" for the last element,
+// "#+" or "#append" for appending, and "#1:3" for slices. Negative indices
+// count from the end, slice bounds are optional and will be limited to
+// the array length.
+func indexOf(index string, length int) (arrayIndex, error) {
+	if index == "" || index[0] != '#' {
+		return arrayIndex{}, ErrNotAnIndex
+	}
+	index = index[1:]
+	if index == "+" || index == "append" {
+		return arrayIndex{kind: indexAppend, from: length}, nil
+	}
+	if colon := strings.Index(index, ":"); colon >= 0 {
+		from, err := boundOf(index[:colon], 0, length)
+		if err != nil {
+			return arrayIndex{}, err
+		}
+		to, err := boundOf(index[colon+1:], length, length)
+		if err != nil {
+			return arrayIndex{}, err
+		}
+		if from > to {
+			from = to
+		}
+		return arrayIndex{kind: indexSlice, from: from, to: to}, nil
+	}
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		return arrayIndex{}, fmt.Errorf("%w: %v", ErrNotAnIndex, err)
+	}
+	if i < 0 {
+		i += length
+	}
+	return arrayIndex{kind: indexSingle, from: i}, nil
+}
+
+// boundOf converts a slice bound for an array of the given length. An
+// empty bound returns the default value.
+func boundOf(bound string, dv, length int) (int, error) {
+	if bound == "" {
+		return dv, nil
+	}
+	b, err := strconv.Atoi(bound)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrNotAnIndex, err)
+	}
+	if b < 0 {
+		b += length
+	}
+	switch {
+	case b < 0:
+		return 0, nil
+	case b > length:
+		return length, nil
+	}
+	return b, nil
+}
+
+// nodeCopy creates a deep copy of the passed node so that changes
+// to the copy won't affect the original.
+func nodeCopy(data interface{}) interface{} {
+	switch d := data.(type) {
+	case map[string]interface{}:
+		cd := make(map[string]interface{}, len(d))
+		for k, v := range d {
+			cd[k] = nodeCopy(v)
+		}
+		return cd
+	case []interface{}:
+		cd := make([]interface{}, len(d))
+		for i, v := range d {
+			cd[i] = nodeCopy(v)
+		}
+		return cd
+	}
+	return data
+}
+
+// nodeValidCopy creates a deep copy of the data like nodeCopy, but
+// only if it and all nested values have valid node types.
+func nodeValidCopy(data interface{}) (interface{}, bool) {
+	switch d := data.(type) {
+	case nil, string, int, float64, bool:
+		return d, true
+	case map[string]interface{}:
+		cd := make(map[string]interface{}, len(d))
+		for k, v := range d {
+			cv, ok := nodeValidCopy(v)
+			if !ok {
+				return nil, false
+			}
+			cd[k] = cv
+		}
+		return cd, true
+	case []interface{}:
+		cd := make([]interface{}, len(d))
+		for i, v := range d {
+			cv, ok := nodeValidCopy(v)
+			if !ok {
+				return nil, false
+			}
+			cd[i] = cv
+		}
+		return cd, true
+	}
+	return nil, false
+}
+
+// appendPath returns a new path with the key appended without
+// sharing the underlying array with the original path.
+func appendPath(path []string, key string) []string {
+	np := make([]string, len(path), len(path)+1)
+	copy(np, path)
+	return append(np, key)
+}
+
+// sortedKeys returns the keys of an object in lexicographical order.
+func sortedKeys(o map[string]interface{}) []string {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//--------------------
+// PATH PATTERNS
+//--------------------
+
+// splitPattern splits a path pattern like "/orders/#*/total" into
+// its parts. Leading and trailing slashes are ignored.
+func splitPattern(pattern string) []string {
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return []string{}
+	}
+	return strings.Split(pattern, "/")
+}
+
+// patternMatches checks if a path matches the parts of a pattern. Each
+// part is matched using stringex.Matches(), so "*" matches any key and
+// "#*" any array index. The part "**" matches any number of path parts.
+func patternMatches(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if patternMatches(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 || !stringex.Matches(pattern[0], path[0], false) {
+		return false
 	}
-	return strconv.Atoi(index[1:])
+	return patternMatches(pattern[1:], path[1:])
 }
 
 // EOF
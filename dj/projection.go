@@ -0,0 +1,251 @@
+// Tideland Go Text - Dynamic JSON
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package dj // import "tideland.dev/go/text/dj"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// FlattenSeparator is used for joining and splitting the keys
+// when flattening and unflattening documents.
+const FlattenSeparator = "."
+
+// FlattenEscape escapes the FlattenSeparator and itself inside of
+// keys when flattening. So the key a.b becomes a\.b and is not split
+// when unflattening.
+const FlattenEscape = `\`
+
+//--------------------
+// PROJECTION
+//--------------------
+
+// Select returns a new document only containing the values matching
+// the passed path patterns. Their positions inside the document
+// stay the same, only selected array elements are kept in their order.
+// The patterns are the same as for Redact().
+func (d *Document) Select(patterns ...string) *Document {
+	parts := make([][]string, len(patterns))
+	for i, pattern := range patterns {
+		parts[i] = splitPattern(pattern)
+	}
+	root, ok := selectNode(d.root, []string{}, parts)
+	if !ok {
+		root = nil
+	}
+	return &Document{
+		root: root,
+	}
+}
+
+// Rename returns a copy of the document where all object keys at
+// paths matching the pattern are renamed to key. Existing values
+// with the new key are overwritten by the renamed ones. If multiple
+// keys of one object are renamed the last one in sorted order wins.
+func (d *Document) Rename(pattern, key string) *Document {
+	return &Document{
+		root: renameNode(d.root, []string{}, splitPattern(pattern), key),
+	}
+}
+
+// Flatten returns a copy of the document where nested objects are
+// flattened into one object with keys joined by the FlattenSeparator.
+// So {"a":{"b":1}} becomes {"a.b":1}. Separators inside of keys are
+// escaped with the FlattenEscape. Arrays are kept as values.
+func (d *Document) Flatten() (*Document, error) {
+	o, ok := d.root.(map[string]interface{})
+	if !ok {
+		return &Document{
+			root: nodeCopy(d.root),
+		}, nil
+	}
+	flat := map[string]interface{}{}
+	if err := flattenNode(flat, "", o); err != nil {
+		return nil, &DocumentError{
+			Action: "flatten document",
+			Err:    err,
+		}
+	}
+	return &Document{
+		root: flat,
+	}, nil
+}
+
+// Unflatten returns a copy of a flattened document where the keys
+// are split by the FlattenSeparator into nested objects again, escaped
+// separators are kept in the keys. In case a key addresses a value as
+// object an error is returned.
+func (d *Document) Unflatten() (*Document, error) {
+	o, ok := d.root.(map[string]interface{})
+	if !ok {
+		return &Document{
+			root: nodeCopy(d.root),
+		}, nil
+	}
+	nested := map[string]interface{}{}
+	for _, key := range sortedKeys(o) {
+		if err := unflattenKey(nested, splitFlatKey(key), nodeCopy(o[key])); err != nil {
+			return nil, &DocumentError{
+				Action: "unflatten document",
+				Err:    err,
+			}
+		}
+	}
+	return &Document{
+		root: nested,
+	}, nil
+}
+
+//--------------------
+// PROJECTION HELPERS
+//--------------------
+
+// selectNode copies all nodes matching one of the patterns. The flag
+// is false if neither the node nor one of its children matches.
+func selectNode(data interface{}, path []string, patterns [][]string) (interface{}, bool) {
+	for _, pattern := range patterns {
+		if patternMatches(pattern, path) {
+			return nodeCopy(data), true
+		}
+	}
+	switch d := data.(type) {
+	case map[string]interface{}:
+		sd := map[string]interface{}{}
+		for k, v := range d {
+			if sv, ok := selectNode(v, appendPath(path, k), patterns); ok {
+				sd[k] = sv
+			}
+		}
+		return sd, len(sd) > 0
+	case []interface{}:
+		sd := []interface{}{}
+		for i, v := range d {
+			if sv, ok := selectNode(v, appendPath(path, "#"+strconv.Itoa(i)), patterns); ok {
+				sd = append(sd, sv)
+			}
+		}
+		return sd, len(sd) > 0
+	}
+	return nil, false
+}
+
+// renameNode copies the node and renames all object keys matching
+// the pattern.
+func renameNode(data interface{}, path, pattern []string, key string) interface{} {
+	switch d := data.(type) {
+	case map[string]interface{}:
+		rd := make(map[string]interface{}, len(d))
+		renamed := []string{}
+		for _, k := range sortedKeys(d) {
+			kpath := appendPath(path, k)
+			if patternMatches(pattern, kpath) {
+				renamed = append(renamed, k)
+				continue
+			}
+			rd[k] = renameNode(d[k], kpath, pattern, key)
+		}
+		// Renamed keys afterwards, so they overwrite existing values.
+		for _, k := range renamed {
+			rd[key] = renameNode(d[k], appendPath(path, k), pattern, key)
+		}
+		return rd
+	case []interface{}:
+		rd := make([]interface{}, len(d))
+		for i, v := range d {
+			rd[i] = renameNode(v, appendPath(path, "#"+strconv.Itoa(i)), pattern, key)
+		}
+		return rd
+	}
+	return data
+}
+
+// flattenNode adds the values of the object to the flat object using
+// the prefix for the keys.
+func flattenNode(flat map[string]interface{}, prefix string, o map[string]interface{}) error {
+	for k, v := range o {
+		fk := prefix + escapeFlatKey(k)
+		if vo, ok := v.(map[string]interface{}); ok && len(vo) > 0 {
+			if err := flattenNode(flat, fk+FlattenSeparator, vo); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := flat[fk]; ok {
+			return fmt.Errorf("duplicate key %q", fk)
+		}
+		flat[fk] = nodeCopy(v)
+	}
+	return nil
+}
+
+// escapeFlatKey escapes the escape and the separator inside
+// of the key.
+func escapeFlatKey(key string) string {
+	key = strings.ReplaceAll(key, FlattenEscape, FlattenEscape+FlattenEscape)
+	return strings.ReplaceAll(key, FlattenSeparator, FlattenEscape+FlattenSeparator)
+}
+
+// splitFlatKey splits the flattened key at the not escaped
+// separators and removes the escapes.
+func splitFlatKey(key string) []string {
+	parts := []string{}
+	var part strings.Builder
+	for len(key) > 0 {
+		switch {
+		case strings.HasPrefix(key, FlattenEscape) && len(key) > len(FlattenEscape):
+			key = key[len(FlattenEscape):]
+			_, size := utf8.DecodeRuneInString(key)
+			part.WriteString(key[:size])
+			key = key[size:]
+		case strings.HasPrefix(key, FlattenSeparator):
+			parts = append(parts, part.String())
+			part.Reset()
+			key = key[len(FlattenSeparator):]
+		default:
+			_, size := utf8.DecodeRuneInString(key)
+			part.WriteString(key[:size])
+			key = key[size:]
+		}
+	}
+	return append(parts, part.String())
+}
+
+// unflattenKey sets the value in the nested object at the position
+// described by the key parts.
+func unflattenKey(nested map[string]interface{}, parts []string, value interface{}) error {
+	head := parts[0]
+	if len(parts) == 1 {
+		if _, ok := nested[head]; ok {
+			return fmt.Errorf("duplicate key %q", head)
+		}
+		nested[head] = value
+		return nil
+	}
+	sub, ok := nested[head]
+	if !ok {
+		sub = map[string]interface{}{}
+		nested[head] = sub
+	}
+	so, ok := sub.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("key %q is no object", head)
+	}
+	return unflattenKey(so, parts[1:], value)
+}
+
+// EOF
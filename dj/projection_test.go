@@ -0,0 +1,142 @@
+// Tideland Go Text - Dynamic JSON - Testing
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package dj_test // import "tideland.dev/go/text/dj"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/text/dj"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+const projectionDocument = `{
+	"id": 4711,
+	"name": {"first": "Foo", "last": "Bar"},
+	"active": true,
+	"orders": [
+		{"id": 1, "total": 10.5, "items": ["a", "b"]},
+		{"id": 2, "total": 20}
+	],
+	"meta": {}
+}`
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDocumentSelect verifies the selection of paths into a new document.
+func TestDocumentSelect(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	doc, err := dj.Parse(bytes.NewBufferString(projectionDocument))
+	assert.NoError(err)
+
+	sel := doc.Select("/id", "/name/last", "/orders/#*/total")
+	assert.Length(sel.Root(), 3)
+	assert.Equal(sel.At("id").AsInt(0), 4711)
+	assert.Equal(sel.At("id").Type(), dj.NodeTypeNumber)
+	assert.Length(sel.At("name"), 1)
+	assert.Equal(sel.At("name", "last").AsString(""), "Bar")
+	assert.ErrorContains(sel.At("name", "first").Error(), "path does not exist")
+	assert.Length(sel.At("orders"), 2)
+	assert.Equal(sel.At("orders", "#1", "total").AsFloat64(0.0), 20.0)
+	assert.ErrorContains(sel.At("orders", "#0", "id").Error(), "path does not exist")
+
+	sel = doc.Select("**/items")
+	assert.Length(sel.At("orders"), 1)
+	assert.Equal(sel.At("orders", "#0", "items", "#1").AsString(""), "b")
+
+	sel = doc.Select("/unknown")
+	assert.True(sel.Root().IsUndefined())
+
+	// Original stays untouched.
+	assert.Length(doc.Root(), 5)
+}
+
+// TestDocumentRename verifies the renaming of keys.
+func TestDocumentRename(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	doc, err := dj.Parse(bytes.NewBufferString(projectionDocument))
+	assert.NoError(err)
+
+	ren := doc.Rename("/name", "fullName")
+	assert.Equal(ren.At("fullName", "first").AsString(""), "Foo")
+	assert.ErrorContains(ren.At("name").Error(), "path does not exist")
+
+	ren = doc.Rename("/orders/#*/total", "sum").Rename("**/id", "key")
+	assert.Equal(ren.At("key").AsInt(0), 4711)
+	assert.Equal(ren.At("orders", "#0", "key").AsInt(0), 1)
+	assert.Equal(ren.At("orders", "#0", "sum").AsFloat64(0.0), 10.5)
+	assert.Equal(ren.At("orders", "#1", "sum").AsInt(0), 20)
+
+	// Renamed values overwrite existing ones.
+	col, err := dj.Parse(bytes.NewBufferString(`{"a":1,"b":2,"c":{"b":3,"z":4}}`))
+	assert.NoError(err)
+	ren = col.Rename("/a", "b").Rename("/c/z", "b")
+	bs, err := ren.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(string(bs), `{"b":1,"c":{"b":4}}`)
+
+	// Original stays untouched.
+	assert.Equal(doc.At("name", "last").AsString(""), "Bar")
+	assert.Equal(doc.At("orders", "#0", "total").AsFloat64(0.0), 10.5)
+}
+
+// TestDocumentFlatten verifies flattening and unflattening of documents.
+func TestDocumentFlatten(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	doc, err := dj.Parse(bytes.NewBufferString(projectionDocument))
+	assert.NoError(err)
+
+	flat, err := doc.Flatten()
+	assert.NoError(err)
+	assert.Length(flat.Root(), 6)
+	assert.Equal(flat.At("name.first").AsString(""), "Foo")
+	assert.Equal(flat.At("active").Type(), dj.NodeTypeBool)
+	assert.Equal(flat.At("orders").Type(), dj.NodeTypeArray)
+	assert.Equal(flat.At("meta").Type(), dj.NodeTypeObject)
+
+	nested, err := flat.Unflatten()
+	assert.NoError(err)
+	assert.True(nested.Root().DeepEqual(doc.Root()))
+
+	// Simple values are kept.
+	doc, err = dj.Parse(bytes.NewBufferString(`"test"`))
+	assert.NoError(err)
+	flat, err = doc.Flatten()
+	assert.NoError(err)
+	assert.Equal(flat.Root().AsString(""), "test")
+
+	// Keys containing separators and escapes.
+	doc, err = dj.Parse(bytes.NewBufferString(`{"a.b": 1, "a": {"b": 2, "c\\d.": 3}}`))
+	assert.NoError(err)
+	flat, err = doc.Flatten()
+	assert.NoError(err)
+	assert.Equal(flat.At(`a\.b`).AsInt(0), 1)
+	assert.Equal(flat.At(`a.b`).AsInt(0), 2)
+	assert.Equal(flat.At(`a.c\\d\.`).AsInt(0), 3)
+	nested, err = flat.Unflatten()
+	assert.NoError(err)
+	assert.True(nested.Root().DeepEqual(doc.Root()))
+
+	// Collisions.
+	doc, err = dj.Parse(bytes.NewBufferString(`{"a": 1, "a.b": 2}`))
+	assert.NoError(err)
+	_, err = doc.Unflatten()
+	assert.ErrorContains(err, "is no object")
+}
+
+// EOF
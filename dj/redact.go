@@ -0,0 +1,152 @@
+// Tideland Go Text - Dynamic JSON
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package dj // import "tideland.dev/go/text/dj"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// RedactAction describes what happens to a value matching
+// a redaction rule.
+type RedactAction int
+
+const (
+	// RedactRemove removes the value from its object or array.
+	RedactRemove RedactAction = iota
+
+	// RedactReplace replaces the value with a fixed mask.
+	RedactReplace
+
+	// RedactKeepLast4 masks all but the last four characters.
+	RedactKeepLast4
+
+	// RedactHash replaces the value with its SHA-256 hash.
+	RedactHash
+)
+
+// RedactMask is the string used for replacing and masking values.
+const RedactMask = "***"
+
+//--------------------
+// REDACTION
+//--------------------
+
+// RedactRule defines a path pattern and the action for all values
+// matching it. The pattern parts are separated by slashes, "*" matches
+// any key or index, "#*" any index, and "**" any number of parts. So
+// "**/password" matches passwords on all levels while "/card/number"
+// only matches the one number.
+type RedactRule struct {
+	Pattern string
+	Action  RedactAction
+}
+
+// Redact returns a copy of the document with all values matching the
+// passed rules redacted. The first matching rule of a value wins, the
+// original document stays untouched.
+func (d *Document) Redact(rules ...RedactRule) *Document {
+	patterns := make([][]string, len(rules))
+	for i, rule := range rules {
+		patterns[i] = splitPattern(rule.Pattern)
+	}
+	r := &redactor{
+		rules:    rules,
+		patterns: patterns,
+	}
+	root, ok := r.redact(d.root, []string{})
+	if !ok {
+		root = nil
+	}
+	return &Document{
+		root: root,
+	}
+}
+
+// redactor performs the redaction of a document.
+type redactor struct {
+	rules    []RedactRule
+	patterns [][]string
+}
+
+// redact walks recursively through the data and returns the redacted
+// copy. The flag is false if the data has to be removed.
+func (r *redactor) redact(data interface{}, path []string) (interface{}, bool) {
+	for i, pattern := range r.patterns {
+		if patternMatches(pattern, path) {
+			return r.apply(r.rules[i].Action, data)
+		}
+	}
+	switch d := data.(type) {
+	case map[string]interface{}:
+		rd := make(map[string]interface{}, len(d))
+		for k, v := range d {
+			if rv, ok := r.redact(v, appendPath(path, k)); ok {
+				rd[k] = rv
+			}
+		}
+		return rd, true
+	case []interface{}:
+		rd := make([]interface{}, 0, len(d))
+		for i, v := range d {
+			if rv, ok := r.redact(v, appendPath(path, "#"+strconv.Itoa(i))); ok {
+				rd = append(rd, rv)
+			}
+		}
+		return rd, true
+	}
+	return data, true
+}
+
+// apply performs the action on the data.
+func (r *redactor) apply(action RedactAction, data interface{}) (interface{}, bool) {
+	switch action {
+	case RedactRemove:
+		return nil, false
+	case RedactKeepLast4:
+		s := []rune(redactString(data))
+		if len(s) <= 4 {
+			return strings.Repeat("*", len(s)), true
+		}
+		return strings.Repeat("*", len(s)-4) + string(s[len(s)-4:]), true
+	case RedactHash:
+		sum := sha256.Sum256([]byte(redactString(data)))
+		return hex.EncodeToString(sum[:]), true
+	}
+	return RedactMask, true
+}
+
+// redactString returns the string representation of the data used
+// for masking and hashing. Objects and arrays are represented as JSON,
+// numbers without exponent, so that e.g. card numbers keep their digits.
+func redactString(data interface{}) string {
+	switch td := data.(type) {
+	case float64:
+		return strconv.FormatFloat(td, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		bs, err := json.Marshal(data)
+		if err != nil {
+			return ""
+		}
+		return string(bs)
+	}
+	return newValue(nil, data, nil).AsString("")
+}
+
+// EOF
@@ -0,0 +1,153 @@
+// Tideland Go Text - Dynamic JSON - Testing
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package dj_test // import "tideland.dev/go/text/dj"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/text/dj"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDocumentRedact verifies the redaction of documents.
+func TestDocumentRedact(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	in := `{
+		"user": "foo",
+		"password": "secret",
+		"card": {"number": "4111111111111111", "owner": "Foo Bar", "pan": 4111111111111111},
+		"accounts": [
+			{"iban": "DE1234567890", "password": "one"},
+			{"iban": "DE0987654321", "password": "two"}
+		],
+		"tokens": ["a", "b", "c"]
+	}`
+	tests := []struct {
+		name  string
+		rules []dj.RedactRule
+		path  []string
+		value string
+		err   string
+	}{
+		{
+			"no rules",
+			[]dj.RedactRule{},
+			[]string{"password"},
+			"secret",
+			"",
+		}, {
+			"remove on all levels",
+			[]dj.RedactRule{{"**/password", dj.RedactRemove}},
+			[]string{"accounts", "#1", "password"},
+			"",
+			"path does not exist",
+		}, {
+			"remove top level",
+			[]dj.RedactRule{{"**/password", dj.RedactRemove}},
+			[]string{"password"},
+			"",
+			"path does not exist",
+		}, {
+			"remove array element",
+			[]dj.RedactRule{{"/tokens/#1", dj.RedactRemove}},
+			[]string{"tokens", "#1"},
+			"c",
+			"",
+		}, {
+			"replace",
+			[]dj.RedactRule{{"/card/number", dj.RedactReplace}},
+			[]string{"card", "number"},
+			"***",
+			"",
+		}, {
+			"replace object",
+			[]dj.RedactRule{{"/card", dj.RedactReplace}},
+			[]string{"card"},
+			"***",
+			"",
+		}, {
+			"keep last 4",
+			[]dj.RedactRule{{"/card/number", dj.RedactKeepLast4}},
+			[]string{"card", "number"},
+			"************1111",
+			"",
+		}, {
+			"keep last 4 of number",
+			[]dj.RedactRule{{"/card/pan", dj.RedactKeepLast4}},
+			[]string{"card", "pan"},
+			"************1111",
+			"",
+		}, {
+			"hash number",
+			[]dj.RedactRule{{"/card/pan", dj.RedactHash}},
+			[]string{"card", "pan"},
+			"9bbef19476623ca56c17da75fd57734dbf82530686043a6e491c6d71befe8f6e",
+			"",
+		}, {
+			"keep last 4 with wildcards",
+			[]dj.RedactRule{{"/accounts/#*/iban", dj.RedactKeepLast4}},
+			[]string{"accounts", "#1", "iban"},
+			"********4321",
+			"",
+		}, {
+			"keep last 4 of short value",
+			[]dj.RedactRule{{"**/password", dj.RedactKeepLast4}},
+			[]string{"accounts", "#0", "password"},
+			"***",
+			"",
+		}, {
+			"hash",
+			[]dj.RedactRule{{"/user", dj.RedactHash}},
+			[]string{"user"},
+			"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+			"",
+		}, {
+			"first rule wins",
+			[]dj.RedactRule{{"/card/*", dj.RedactReplace}, {"**/number", dj.RedactRemove}},
+			[]string{"card", "number"},
+			"***",
+			"",
+		}, {
+			"untouched value",
+			[]dj.RedactRule{{"**/password", dj.RedactRemove}},
+			[]string{"card", "owner"},
+			"Foo Bar",
+			"",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			defer assert.SetFailable(t)()
+			doc, err := dj.Parse(bytes.NewBufferString(in))
+			assert.NoError(err)
+			redacted := doc.Redact(test.rules...)
+			value := redacted.At(test.path...)
+			if test.err != "" {
+				assert.ErrorContains(value.Error(), test.err)
+			} else {
+				assert.Equal(value.AsString(""), test.value)
+			}
+			// Original has to be untouched.
+			assert.Equal(doc.At("password").AsString(""), "secret")
+			assert.Equal(doc.At("card", "number").AsString(""), "4111111111111111")
+			assert.Length(doc.At("tokens"), 3)
+		})
+	}
+}
+
+// EOF
@@ -12,7 +12,6 @@ package dj // import "tideland.dev/go/text/dj"
 //--------------------
 
 import (
-	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -56,7 +55,8 @@ func (v *Value) Set(data interface{}) {
 		v.data = nil
 		v.err = &ValueError{
 			Mode: "set",
-			Err:  errors.New("invalid type"),
+			Path: v.path,
+			Err:  ErrInvalidType,
 		}
 	}
 }
@@ -122,27 +122,34 @@ func (v *Value) AsInt(dv int) int {
 
 // AsFloat64 returns the value as float64.
 func (v *Value) AsFloat64(dv float64) float64 {
-	if v.IsUndefined() {
+	f, ok := asFloat64(v.data)
+	if !ok {
 		return dv
 	}
-	switch tv := v.data.(type) {
+	return f
+}
+
+// asFloat64 converts the data into a float64. The flag is false
+// if the data is undefined or cannot be converted.
+func asFloat64(data interface{}) (float64, bool) {
+	switch td := data.(type) {
 	case string:
-		f, err := strconv.ParseFloat(tv, 64)
+		f, err := strconv.ParseFloat(td, 64)
 		if err != nil {
-			return dv
+			return 0.0, false
 		}
-		return f
+		return f, true
 	case int:
-		return float64(tv)
+		return float64(td), true
 	case float64:
-		return tv
+		return td, true
 	case bool:
-		if tv {
-			return 1.0
+		if td {
+			return 1.0, true
 		}
-		return 0.0
+		return 0.0, true
 	}
-	return dv
+	return 0.0, false
 }
 
 // AsBool returns the value as bool.
@@ -192,8 +199,8 @@ func (v *Value) Error() error {
 
 // At retrieves a value at a given path of keys.
 func (v *Value) At(path ...string) *Value {
-	jpath := append(v.path, path...)
-	data, err := nodeAt(v.data, []string{}, path)
+	jpath := append(append([]string{}, v.path...), path...)
+	data, err := nodeAt(v.data, v.path, path)
 	if err != nil {
 		return newValue(jpath, nil, err)
 	}
@@ -7,6 +7,78 @@
 
 package gjp // import "tideland.dev/go/text/gjp"
 
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// OPTIONS
+//--------------------
+
+// CompareOption defines a function setting an option for comparing.
+type CompareOption func(d *Diff) error
+
+// IgnorePaths lets the comparing ignore all paths matching one of the
+// patterns. They are matched like by MatchPath(), and also the children
+// of a matching path are ignored.
+func IgnorePaths(patterns ...string) CompareOption {
+	return func(d *Diff) error {
+		d.ignored = append(d.ignored, patterns...)
+		return nil
+	}
+}
+
+// FloatTolerance lets the comparing treat numbers as equal if their
+// difference is not larger than the tolerance.
+func FloatTolerance(tolerance float64) CompareOption {
+	return func(d *Diff) error {
+		if tolerance < 0 {
+			return failure.New("negative tolerance is not allowed: %v", tolerance)
+		}
+		d.tolerance = tolerance
+		return nil
+	}
+}
+
+// IgnoreCase lets the comparing treat strings as equal independent
+// of their case.
+func IgnoreCase() CompareOption {
+	return func(d *Diff) error {
+		d.ignoreCase = true
+		return nil
+	}
+}
+
+// IgnoreArrayOrder lets the comparing treat arrays as equal if they
+// contain the same elements in any order. For this the arrays of both
+// documents are sorted, so the differences and the documents returned
+// by FirstDocument() and SecondDocument() address the sorted arrays.
+func IgnoreArrayOrder() CompareOption {
+	return func(d *Diff) error {
+		d.ignoreArrayOrder = true
+		return nil
+	}
+}
+
+// DistinguishMissing lets the comparing treat missing paths as different
+// from paths containing null. Otherwise a null value in one document
+// equals a missing path in the other one.
+func DistinguishMissing() CompareOption {
+	return func(d *Diff) error {
+		d.distinguishMissing = true
+		return nil
+	}
+}
+
 //--------------------
 // DIFFERENCE
 //--------------------
@@ -16,10 +88,17 @@ type Diff struct {
 	first  *Document
 	second *Document
 	paths  []string
+
+	ignored            []string
+	tolerance          float64
+	ignoreCase         bool
+	ignoreArrayOrder   bool
+	distinguishMissing bool
 }
 
 // Compare parses and compares the documents and returns their differences.
-func Compare(first, second []byte, separator string) (*Diff, error) {
+// The options allow to control the comparing.
+func Compare(first, second []byte, separator string, options ...CompareOption) (*Diff, error) {
 	fd, err := Parse(first, separator)
 	if err != nil {
 		return nil, err
@@ -28,25 +107,38 @@ func Compare(first, second []byte, separator string) (*Diff, error) {
 	if err != nil {
 		return nil, err
 	}
-	d := &Diff{
-		first:  fd,
-		second: sd,
-	}
-	err = d.compare()
-	if err != nil {
-		return nil, err
-	}
-	return d, nil
+	return newDiff(fd, sd, options)
 }
 
 // CompareDocuments compares the documents and returns their differences.
-func CompareDocuments(first, second *Document, separator string) (*Diff, error) {
+// The options allow to control the comparing.
+func CompareDocuments(first, second *Document, separator string, options ...CompareOption) (*Diff, error) {
 	first.separator = separator
 	second.separator = separator
+	return newDiff(first, second, options)
+}
+
+// newDiff creates the diff for the documents and runs the comparing.
+func newDiff(first, second *Document, options []CompareOption) (*Diff, error) {
 	d := &Diff{
 		first:  first,
 		second: second,
 	}
+	for _, option := range options {
+		if err := option(d); err != nil {
+			return nil, err
+		}
+	}
+	if d.ignoreArrayOrder {
+		d.first = &Document{
+			separator: first.separator,
+			root:      d.sortArrays(copyNode(first.root)),
+		}
+		d.second = &Document{
+			separator: second.separator,
+			root:      d.sortArrays(copyNode(second.root)),
+		}
+	}
 	err := d.compare()
 	if err != nil {
 		return nil, err
@@ -65,7 +157,9 @@ func (d *Diff) SecondDocument() *Document {
 }
 
 // Differences returns a list of paths where the documents
-// have different content.
+// have different content. First come the paths of the first
+// document, then those only existing in the second one, each
+// in the order of ProcessSorted().
 func (d *Diff) Differences() []string {
 	return d.paths
 }
@@ -81,28 +175,125 @@ func (d *Diff) DifferenceAt(path string) (*Value, *Value) {
 // compare iterates over the both documents looking for different
 // values or even paths.
 func (d *Diff) compare() error {
+	ignored := make([]Matcher, len(d.ignored))
+	for i, pattern := range d.ignored {
+		ignored[i] = MatchPath(pattern+d.first.separator+"**", d.first.separator)
+	}
+	isIgnored := func(path string, value *Value) bool {
+		for _, matcher := range ignored {
+			if matcher(path, value) {
+				return true
+			}
+		}
+		return false
+	}
 	firstPaths := map[string]struct{}{}
 	firstProcessor := func(path string, value *Value) error {
 		firstPaths[path] = struct{}{}
-		if !value.Equals(d.second.ValueAt(path)) {
+		if isIgnored(path, value) {
+			return nil
+		}
+		second := d.second.ValueAt(path)
+		if (d.distinguishMissing && second.err != nil) || !d.equal(value.raw, second.raw) {
 			d.paths = append(d.paths, path)
 		}
 		return nil
 	}
-	err := d.first.Process(firstProcessor)
+	err := d.first.ProcessSorted(firstProcessor)
 	if err != nil {
 		return err
 	}
 	secondProcessor := func(path string, value *Value) error {
 		_, ok := firstPaths[path]
-		if ok {
+		if ok || isIgnored(path, value) {
 			// Been there, done that.
 			return nil
 		}
 		d.paths = append(d.paths, path)
 		return nil
 	}
-	return d.second.Process(secondProcessor)
+	return d.second.ProcessSorted(secondProcessor)
+}
+
+// equal compares two raw values recursively using the options.
+func (d *Diff) equal(first, second interface{}) bool {
+	switch tf := first.(type) {
+	case map[string]interface{}:
+		ts, ok := second.(map[string]interface{})
+		if !ok || len(tf) != len(ts) {
+			return false
+		}
+		for field, subnode := range tf {
+			ssubnode, ok := ts[field]
+			if (d.distinguishMissing && !ok) || !d.equal(subnode, ssubnode) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		ts, ok := second.([]interface{})
+		if !ok || len(tf) != len(ts) {
+			return false
+		}
+		for index, subnode := range tf {
+			if !d.equal(subnode, ts[index]) {
+				return false
+			}
+		}
+		return true
+	case string:
+		ts, ok := second.(string)
+		if ok && d.ignoreCase {
+			return strings.EqualFold(tf, ts)
+		}
+		return ok && tf == ts
+	case int, float64:
+		ff, _ := asNumber(first)
+		switch second.(type) {
+		case int, float64:
+			sf, _ := asNumber(second)
+			return math.Abs(ff-sf) <= d.tolerance
+		}
+		return false
+	}
+	return reflect.DeepEqual(first, second)
+}
+
+// sortArrays sorts all arrays of the node recursively by the JSON
+// representation of their elements.
+func (d *Diff) sortArrays(node interface{}) interface{} {
+	if o, ok := isObject(node); ok {
+		for field, subnode := range o {
+			o[field] = d.sortArrays(subnode)
+		}
+		return o
+	}
+	if a, ok := isArray(node); ok {
+		keys := make(map[int]string, len(a))
+		for index, subnode := range a {
+			a[index] = d.sortArrays(subnode)
+		}
+		for index, subnode := range a {
+			key := jsonOf(subnode)
+			if d.ignoreCase {
+				key = strings.ToLower(key)
+			}
+			keys[index] = key
+		}
+		indices := make([]int, len(a))
+		for index := range indices {
+			indices[index] = index
+		}
+		sort.SliceStable(indices, func(i, j int) bool {
+			return keys[indices[i]] < keys[indices[j]]
+		})
+		sorted := make([]interface{}, len(a))
+		for index, from := range indices {
+			sorted[index] = a[from]
+		}
+		return sorted
+	}
+	return node
 }
 
 // EOF
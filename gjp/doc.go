@@ -27,19 +27,75 @@
 //
 //     err := doc.SetValueAt("a/b/3/c", 4711)
 //
+// Keys containing the separator are addressed by escaping it with a
+// backslash, a backslash itself is doubled. PathOf() creates such paths
+// out of keys, and also the paths passed to processors are escaped.
+// Empty keys are empty parts like in "a//b", a trailing separator marks
+// an empty last key like in "a//".
+//
+//     url := doc.ValueAt(doc.PathOf("links", "http://tideland.dev")).AsString("")
+//
+// Whole objects and arrays, also created from maps, slices, or structs,
+// can be set with
+//
+//     err := doc.SetTreeAt("a/b", myStruct, gjp.SetOverwrite)
+//
+// Values and whole subtrees can be removed, moved, or copied with
+//
+//     err := doc.RemoveValueAt("a/b/3")
+//     err := doc.MoveValueAt("a/b/2", "x/y")
+//     err := doc.CopyValueAt("x", "z")
+//
 // Additionally values of the document can be processed using
 //
 //     err := doc.Process(func(path string, value gjp.Value) error {
 //         ...
 //     })
 //
+// Here the order of the paths is random, while ProcessSorted() processes
+// them in a deterministic order. The latter is also used by Query() and
+// the comparing of documents.
+//
+// Query() finds values by a pattern for the whole path, while QueryExpr()
+// supports patterns per path part including "**" for any number of parts,
+// regular expressions, and predicates for values like
+//
+//     pvs, err := doc.QueryExpr("/users/*/age > 30")
+//
+// A Document is not safe for concurrent usage. Here a SyncDocument
+// wraps a copy of it and changes it copy-on-write. Multiple changes can
+// be done atomically with
+//
+//     err := sdoc.Update(func(doc *gjp.Document) error {
+//         ...
+//     })
+//
 // Sometimes one is more interested in the differences between two
 // documents. Here
 //
 //     diff, err := gjp.Compare(firstDoc, secondDoc, "/")
 //
 // privides a gjp.Diff instance which helps to compare individual
-// paths of the two document.
+// paths of the two document. Its Report() classifies the differences
+// as added, removed, changed, or type-changed, and RenderText(),
+// RenderTerminal(), and RenderJSON() write them in a readable way.
+// Options like IgnorePaths(), FloatTolerance(), IgnoreCase(),
+// IgnoreArrayOrder(), and DistinguishMissing() control the comparing.
+//
+// Merge3() merges the changes of two documents based on a common base
+// document. Changes at different paths are merged, the others are returned
+// as conflicts. Options like PreferOurs(), PreferTheirs(), or ResolveWith()
+// resolve them.
+//
+// Infer() walks over a document and summarizes its paths with observed
+// types, nullability, occurrences, and ranges of lengths and numbers. The
+// Inference can be extended with more samples using Add() or Merge() and
+// returns an inferred JSON Schema with Schema().
+//
+// As dj is the successor of gjp, ToDJ() and FromDJ() convert documents,
+// and ToDJPath() and FromDJPath() translate paths. The Accessor interface
+// describes the Document API and is also implemented by DJDocument on top
+// of a dj document, so code can be migrated incrementally.
 package gjp // import "tideland.dev/go/text/gjp"
 
 // EOF
@@ -18,6 +18,20 @@ import (
 	"tideland.dev/go/trace/failure"
 )
 
+//--------------------
+// CONSTANTS
+//--------------------
+
+// DefaultSeparator is used for documents unmarshalled without
+// a separator set before.
+const DefaultSeparator = "/"
+
+// PathEscape allows to use separators inside of keys by escaping
+// them, e.g. a/http:\/\/example.com/b. The escape itself is escaped
+// by doubling it. Empty keys are empty parts between separators like
+// in /a//b, a trailing separator marks an empty last key like in /a//.
+const PathEscape = `\`
+
 //--------------------
 // DOCUMENT
 //--------------------
@@ -35,6 +49,22 @@ type PathValues []PathValue
 // values while iterating over a document.
 type ValueProcessor func(path string, value *Value) error
 
+// SetMode defines how setting values handles the content already
+// existing at a path.
+type SetMode int
+
+const (
+	// SetKeep only sets values at paths without content.
+	SetKeep SetMode = iota
+
+	// SetValues replaces existing simple values but neither
+	// objects nor arrays. It's the mode of SetValueAt().
+	SetValues
+
+	// SetOverwrite replaces any existing content at the path.
+	SetOverwrite
+)
+
 // Document represents one JSON document.
 type Document struct {
 	separator string
@@ -62,6 +92,12 @@ func NewDocument(separator string) *Document {
 	}
 }
 
+// PathOf creates a path out of the parts using the separator of
+// the document. Separators inside of the parts are escaped.
+func (d *Document) PathOf(parts ...string) string {
+	return pathify(parts, d.separator)
+}
+
 // Length returns the number of elements for the given path.
 func (d *Document) Length(path string) int {
 	n, err := valueAt(d.root, splitPath(path, d.separator))
@@ -83,7 +119,7 @@ func (d *Document) Length(path string) int {
 // SetValueAt sets the value at the given path.
 func (d *Document) SetValueAt(path string, value interface{}) error {
 	parts := splitPath(path, d.separator)
-	root, err := setValueAt(d.root, value, parts)
+	root, err := setValueAt(d.root, value, parts, SetValues)
 	if err != nil {
 		return err
 	}
@@ -91,10 +127,82 @@ func (d *Document) SetValueAt(path string, value interface{}) error {
 	return nil
 }
 
+// SetTreeAt sets any JSON compatible value at the given path. This
+// includes maps, slices, and structs marshalled using their JSON tags.
+// The mode controls if existing content at the path will be replaced.
+func (d *Document) SetTreeAt(path string, value interface{}, mode SetMode) error {
+	raw, err := normalizeValue(value)
+	if err != nil {
+		return failure.Annotate(err, "cannot set tree at '%s'", path)
+	}
+	parts := splitPath(path, d.separator)
+	root, err := setChildValueAt(copyNode(d.root), raw, parts, mode)
+	if err != nil {
+		return failure.Annotate(err, "cannot set tree at '%s'", path)
+	}
+	d.root = root
+	return nil
+}
+
+// RemoveValueAt removes the value at the given path. In case of
+// an array element the following elements move forward.
+func (d *Document) RemoveValueAt(path string) error {
+	parts := splitPath(path, d.separator)
+	root, err := removeValueAt(d.root, parts)
+	if err != nil {
+		return failure.Annotate(err, "cannot remove value at '%s'", path)
+	}
+	d.root = root
+	return nil
+}
+
+// MoveValueAt moves the value or subtree at path from to the
+// path to. Like setting values it won't replace objects or arrays
+// at the target. In case of an error the document is unchanged.
+func (d *Document) MoveValueAt(from, to string) error {
+	fromParts := splitPath(from, d.separator)
+	toParts := splitPath(to, d.separator)
+	if isPrefix(fromParts, toParts) {
+		return failure.New("cannot move '%s' into itself at '%s'", from, to)
+	}
+	value, err := valueAt(d.root, fromParts)
+	if err != nil {
+		return failure.Annotate(err, "cannot move value at '%s'", from)
+	}
+	root, err := removeValueAt(copyNode(d.root), fromParts)
+	if err != nil {
+		return failure.Annotate(err, "cannot move value at '%s'", from)
+	}
+	root, err = setValueAt(root, copyNode(value), toParts, SetValues)
+	if err != nil {
+		return failure.Annotate(err, "cannot move value to '%s'", to)
+	}
+	d.root = root
+	return nil
+}
+
+// CopyValueAt copies the value or subtree at path from to the
+// path to. Like setting values it won't replace objects or arrays
+// at the target. In case of an error the document is unchanged.
+func (d *Document) CopyValueAt(from, to string) error {
+	fromParts := splitPath(from, d.separator)
+	toParts := splitPath(to, d.separator)
+	value, err := valueAt(d.root, fromParts)
+	if err != nil {
+		return failure.Annotate(err, "cannot copy value at '%s'", from)
+	}
+	root, err := setValueAt(copyNode(d.root), copyNode(value), toParts, SetValues)
+	if err != nil {
+		return failure.Annotate(err, "cannot copy value to '%s'", to)
+	}
+	d.root = root
+	return nil
+}
+
 // ValueAt returns the addressed value.
 func (d *Document) ValueAt(path string) *Value {
 	n, err := valueAt(d.root, splitPath(path, d.separator))
-	return &Value{n, err}
+	return &Value{n, d.separator, err}
 }
 
 // Clear removes the so far build document data.
@@ -102,10 +210,14 @@ func (d *Document) Clear() {
 	d.root = nil
 }
 
-// Query allows to find pathes matching a given pattern.
+// Query allows to find pathes matching a given pattern. The pattern
+// is matched against the whole path like by stringex.Matches(), so
+// "*" also matches separators. The syntax of QueryExpr() with "**",
+// regular expressions, and value predicates is not supported here.
+// The result is sorted like by ProcessSorted().
 func (d *Document) Query(pattern string) (PathValues, error) {
 	pvs := PathValues{}
-	err := d.Process(func(path string, value *Value) error {
+	err := d.ProcessSorted(func(path string, value *Value) error {
 		if stringex.Matches(pattern, path, false) {
 			pvs = append(pvs, PathValue{
 				Path:  path,
@@ -121,7 +233,33 @@ func (d *Document) Query(pattern string) (PathValues, error) {
 // There's no order, so nesting into an embedded document or
 // list may come earlier than higher level paths.
 func (d *Document) Process(processor ValueProcessor) error {
-	return process(d.root, []string{}, d.separator, processor)
+	return process(d.root, []string{}, d.separator, false, processor)
+}
+
+// ProcessSorted iterates over a document and processes its values
+// in a deterministic order. Fields of objects are processed in
+// lexicographical order, elements of arrays in numerical order, and
+// a node is processed completely before its next sibling. So parent
+// paths always come before the paths of their children.
+func (d *Document) ProcessSorted(processor ValueProcessor) error {
+	return process(d.root, []string{}, d.separator, true, processor)
+}
+
+// Bind unmarshals the value or subtree at the given path into the
+// target like json.Unmarshal() does, so JSON tags are used.
+func (d *Document) Bind(path string, target interface{}) error {
+	n, err := valueAt(d.root, splitPath(path, d.separator))
+	if err != nil {
+		return failure.Annotate(err, "cannot bind value at '%s'", path)
+	}
+	bs, err := json.Marshal(n)
+	if err != nil {
+		return failure.Annotate(err, "cannot bind value at '%s'", path)
+	}
+	if err := json.Unmarshal(bs, target); err != nil {
+		return failure.Annotate(err, "cannot bind value at '%s'", path)
+	}
+	return nil
 }
 
 // MarshalJSON implements json.Marshaler.
@@ -129,4 +267,18 @@ func (d *Document) MarshalJSON() ([]byte, error) {
 	return json.Marshal(d.root)
 }
 
+// UnmarshalJSON implements json.Unmarshaler. If the document
+// has no separator yet DefaultSeparator is used.
+func (d *Document) UnmarshalJSON(data []byte) error {
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return failure.Annotate(err, "cannot unmarshal document")
+	}
+	if d.separator == "" {
+		d.separator = DefaultSeparator
+	}
+	d.root = root
+	return nil
+}
+
 // EOF
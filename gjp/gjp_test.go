@@ -12,12 +12,16 @@ package gjp_test
 //--------------------
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/text/dj"
 	"tideland.dev/go/text/gjp"
 )
 
@@ -97,6 +101,94 @@ func TestProcessing(t *testing.T) {
 	assert.ErrorMatch(err, `.*ouch.*`)
 }
 
+// TestProcessingSorted tests the sorted processing of a document.
+func TestProcessingSorted(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	bs := []byte(`{"c":{"z":1,"a":[3,{"y":true,"x":false}]},"b":[],"a":"foo","d":{}}`)
+	expected := []string{"/a", "/b", "/c/a/0", "/c/a/1/x", "/c/a/1/y", "/c/z", "/d"}
+
+	doc, err := gjp.Parse(bs, "/")
+	assert.Nil(err)
+	for i := 0; i < 10; i++ {
+		paths := []string{}
+		err = doc.ProcessSorted(func(path string, value *gjp.Value) error {
+			paths = append(paths, path)
+			return nil
+		})
+		assert.Nil(err)
+		assert.Equal(paths, expected)
+	}
+
+	// Query and Diff are sorted too.
+	pvs, err := doc.Query("/c/*")
+	assert.Nil(err)
+	assert.Length(pvs, 4)
+	for i, pv := range pvs {
+		assert.Equal(pv.Path, expected[i+2])
+	}
+	diff, err := gjp.Compare(bs, []byte(`{"e":1,"a":"bar","c":{"z":2}}`), "/")
+	assert.Nil(err)
+	assert.Equal(diff.Differences(), []string{"/a", "/b", "/c/a/0", "/c/a/1/x", "/c/a/1/y", "/c/z", "/d", "/e"})
+}
+
+// TestEscapedSeparators tests keys containing the separator.
+func TestEscapedSeparators(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	bs := []byte(`{"links":{"http://tideland.dev":"home","a\\b":"backslash"},"x/y":[1,2]}`)
+
+	doc, err := gjp.Parse(bs, "/")
+	assert.Nil(err)
+	assert.Equal(doc.PathOf("links", "http://tideland.dev"), `/links/http:\/\/tideland.dev`)
+	assert.Equal(doc.PathOf("links", `a\b`), `/links/a\\b`)
+	assert.Equal(doc.ValueAt(doc.PathOf("links", "http://tideland.dev")).AsString(""), "home")
+	assert.Equal(doc.ValueAt(`links/a\\b`).AsString(""), "backslash")
+	assert.Equal(doc.ValueAt(`x\/y/1`).AsInt(0), 2)
+	assert.Equal(doc.Length(`x\/y`), 2)
+
+	// Processed paths round trip.
+	paths := []string{}
+	err = doc.ProcessSorted(func(path string, value *gjp.Value) error {
+		paths = append(paths, path)
+		assert.Equal(doc.ValueAt(path).AsString("-"), value.AsString("+"))
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(paths, []string{`/links/a\\b`, `/links/http:\/\/tideland.dev`, `/x\/y/0`, `/x\/y/1`})
+
+	// Setting and other separators.
+	doc = gjp.NewDocument("::")
+	err = doc.SetValueAt(doc.PathOf("a::b", "c"), 1)
+	assert.Nil(err)
+	assert.Equal(doc.ValueAt(`a\::b::c`).AsInt(0), 1)
+	bs, err = doc.MarshalJSON()
+	assert.Nil(err)
+	assert.Equal(string(bs), `{"a::b":{"c":1}}`)
+
+	// Empty keys.
+	doc, err = gjp.Parse([]byte(`{"":1,"a":{"":2,"b":{"":3}}}`), "/")
+	assert.Nil(err)
+	paths = []string{}
+	err = doc.ProcessSorted(func(path string, value *gjp.Value) error {
+		paths = append(paths, path)
+		assert.Equal(doc.ValueAt(path).AsInt(0), value.AsInt(-1))
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(paths, []string{`//`, `/a//`, `/a/b//`})
+	assert.Equal(doc.PathOf("a", "", "b"), `/a//b`)
+	assert.Equal(doc.Length("/"), 2)
+	assert.Equal(doc.Length("a/"), 2)
+	err = doc.SetValueAt(doc.PathOf("c", "", "d"), 4)
+	assert.Nil(err)
+	assert.Equal(doc.ValueAt("/c//d").AsInt(0), 4)
+	assert.Equal(doc.ValueAt("/c//").Kind(), gjp.KindObject)
+	err = doc.RemoveValueAt("a/b//")
+	assert.Nil(err)
+	bs, err = doc.MarshalJSON()
+	assert.Nil(err)
+	assert.Equal(string(bs), `{"":1,"a":{"":2,"b":{}},"c":{"":{"d":4}}}`)
+}
+
 // TestSeparator tests using different separators.
 func TestSeparator(t *testing.T) {
 	assert := asserts.NewTesting(t, asserts.FailStop)
@@ -195,6 +287,392 @@ func TestCompare(t *testing.T) {
 	assert.Length(diff.Differences(), 4)
 }
 
+// TestDiffReport tests the classified differences and their rendering.
+func TestDiffReport(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	first := []byte(`{"a":"foo","b":1,"c":[1,2],"d":true,"e":null}`)
+	second := []byte(`{"a":"bar","b":"1","c":[1],"e":null,"f":{"x":1}}`)
+
+	diff, err := gjp.Compare(first, second, "/")
+	assert.Nil(err)
+	report := diff.Report()
+	assert.Length(report, 5)
+	expected := []struct {
+		path string
+		kind gjp.DifferenceKind
+	}{
+		{"/a", gjp.Changed},
+		{"/b", gjp.TypeChanged},
+		{"/c/1", gjp.Removed},
+		{"/d", gjp.Removed},
+		{"/f/x", gjp.Added},
+	}
+	for i, e := range expected {
+		assert.Equal(report[i].Path, e.path)
+		assert.Equal(report[i].Kind, e.kind, e.path)
+	}
+	assert.Equal(report[0].First.AsString(""), "foo")
+	assert.Equal(report[0].Second.AsString(""), "bar")
+	assert.Nil(report[2].Second)
+	assert.Nil(report[4].First)
+	assert.Equal(gjp.TypeChanged.String(), "type-changed")
+
+	// Renderings.
+	var buf bytes.Buffer
+	err = diff.RenderText(&buf)
+	assert.Nil(err)
+	assert.Equal(buf.String(), `--- first
++++ second
+- /a = "foo"
++ /a = "bar"
+- /b = 1 (number)
++ /b = "1" (string)
+- /c/1 = 2
+- /d = true
++ /f/x = 1
+`)
+
+	buf.Reset()
+	err = diff.RenderTerminal(&buf)
+	assert.Nil(err)
+	assert.Contains("\033[31m- /a = \"foo\"\033[0m\n", buf.String())
+	assert.Contains("\033[33m+ /b = \"1\" (string)\033[0m\n", buf.String())
+	assert.Contains("\033[32m+ /f/x = 1\033[0m\n", buf.String())
+
+	buf.Reset()
+	err = diff.RenderJSON(&buf)
+	assert.Nil(err)
+	var jds []map[string]interface{}
+	err = json.Unmarshal(buf.Bytes(), &jds)
+	assert.Nil(err)
+	assert.Length(jds, 5)
+	assert.Equal(jds[1]["kind"], "type-changed")
+	assert.Equal(jds[1]["first"], 1.0)
+	assert.Equal(jds[1]["second"], "1")
+	_, ok := jds[2]["second"]
+	assert.False(ok)
+
+	// Removed and added null values, the roots differ too.
+	kinds := func(first, second string, options ...gjp.CompareOption) map[string]gjp.DifferenceKind {
+		diff, err := gjp.Compare([]byte(first), []byte(second), "/", options...)
+		assert.Nil(err)
+		ks := map[string]gjp.DifferenceKind{}
+		for _, difference := range diff.Report() {
+			ks[difference.Path] = difference.Kind
+		}
+		return ks
+	}
+	assert.Equal(kinds(`{"a":null}`, `{}`), map[string]gjp.DifferenceKind{"/": gjp.Changed})
+	assert.Equal(kinds(`{"a":null}`, `{}`, gjp.DistinguishMissing()), map[string]gjp.DifferenceKind{"/": gjp.Changed, "/a": gjp.Removed})
+	assert.Equal(kinds(`{}`, `{"a":null}`, gjp.DistinguishMissing()), map[string]gjp.DifferenceKind{"/": gjp.Changed, "/a": gjp.Added})
+}
+
+// TestCompareOptions tests comparing documents with options.
+func TestCompareOptions(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	first := []byte(`{
+		"id": "a1",
+		"ts": "2021-01-01T10:00:00Z",
+		"meta": {"request": "r1", "host": "h1"},
+		"value": 1.0001,
+		"name": "Foo",
+		"tags": ["x", "y", "Z"],
+		"items": [{"n": 1}, {"n": 2}]
+	}`)
+	second := []byte(`{
+		"id": "a1",
+		"ts": "2021-01-01T10:00:05Z",
+		"meta": {"request": "r2", "host": "h2", "port": 80},
+		"value": 1.0002,
+		"name": "foo",
+		"tags": ["z", "X", "y"],
+		"items": [{"n": 2}, {"n": 1}]
+	}`)
+	tests := []struct {
+		name    string
+		options []gjp.CompareOption
+		paths   []string
+		err     string
+	}{
+		{
+			"no options",
+			nil,
+			[]string{"/items/0/n", "/items/1/n", "/meta/host", "/meta/request", "/name",
+				"/tags/0", "/tags/1", "/tags/2", "/ts", "/value", "/meta/port"},
+			"",
+		}, {
+			"ignore paths",
+			[]gjp.CompareOption{gjp.IgnorePaths("/ts", "/meta")},
+			[]string{"/items/0/n", "/items/1/n", "/name", "/tags/0", "/tags/1", "/tags/2", "/value"},
+			"",
+		}, {
+			"ignore paths with patterns",
+			[]gjp.CompareOption{gjp.IgnorePaths("**/request", "/t*", "/items/*/n")},
+			[]string{"/meta/host", "/name", "/value", "/meta/port"},
+			"",
+		}, {
+			"float tolerance",
+			[]gjp.CompareOption{gjp.IgnorePaths("/ts", "/meta", "/tags", "/items"), gjp.FloatTolerance(0.001)},
+			[]string{"/name"},
+			"",
+		}, {
+			"ignore case",
+			[]gjp.CompareOption{gjp.IgnorePaths("/ts", "/meta", "/items"), gjp.IgnoreCase()},
+			[]string{"/tags/0", "/tags/1", "/tags/2", "/value"},
+			"",
+		}, {
+			"ignore array order",
+			[]gjp.CompareOption{gjp.IgnorePaths("/ts", "/meta"), gjp.IgnoreArrayOrder()},
+			[]string{"/name", "/tags/0", "/tags/1", "/tags/2", "/value"},
+			"",
+		}, {
+			"all options",
+			[]gjp.CompareOption{
+				gjp.IgnorePaths("/ts", "/meta"),
+				gjp.FloatTolerance(0.001),
+				gjp.IgnoreCase(),
+				gjp.IgnoreArrayOrder(),
+			},
+			nil,
+			"",
+		}, {
+			"invalid tolerance",
+			[]gjp.CompareOption{gjp.FloatTolerance(-1.0)},
+			nil,
+			"negative tolerance",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			defer assert.SetFailable(t)()
+			diff, err := gjp.Compare(first, second, "/", test.options...)
+			if test.err != "" {
+				assert.ErrorMatch(err, ".*"+test.err+".*")
+				return
+			}
+			assert.Nil(err)
+			if test.paths == nil {
+				assert.Length(diff.Differences(), 0)
+			} else {
+				assert.Equal(diff.Differences(), test.paths)
+			}
+		})
+	}
+}
+
+// TestMerge3 tests the three-way merging of documents.
+func TestMerge3(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	parse := func(s string) *gjp.Document {
+		doc, err := gjp.Parse([]byte(s), "/")
+		assert.Nil(err)
+		return doc
+	}
+	base := parse(`{"name":"svc","port":80,"tags":["a","b"],"db":{"host":"h1","pool":5},
+		"debug":false,"old":1,"version":1,"mode":"a","list":[1,2,3,4],"opts":{"a":1}}`)
+	ours := parse(`{"name":"svc","port":8080,"tags":["a","b","c"],"db":{"host":"h2","pool":5},
+		"debug":false,"version":2,"list":[1,2],"opts":{"a":2}}`)
+	theirs := parse(`{"name":"service","port":80,"tags":["a","b"],"db":{"host":"h3","pool":10},
+		"debug":true,"old":1,"version":2,"mode":"b","list":[1,2,3,4],"opts":"none","new":{"x":1}}`)
+	merged := func(host, mode, opts string) string {
+		if mode != "" {
+			mode = `"mode":` + mode + `,`
+		}
+		return `{"db":{"host":"` + host + `","pool":10},"debug":true,"list":[1,2],` + mode +
+			`"name":"service","new":{"x":1},"opts":` + opts + `,"port":8080,"tags":["a","b","c"],"version":2}`
+	}
+
+	tests := []struct {
+		name      string
+		options   []gjp.MergeOption
+		merged    string
+		conflicts []string
+		resolved  []string
+	}{
+		{
+			"manual",
+			nil,
+			merged("h1", `"a"`, `{"a":1}`),
+			[]string{"/db/host", "/mode", "/opts"},
+			nil,
+		}, {
+			"prefer ours",
+			[]gjp.MergeOption{gjp.PreferOurs()},
+			merged("h2", "", `{"a":2}`),
+			nil,
+			[]string{"/db/host", "/mode", "/opts"},
+		}, {
+			"prefer theirs",
+			[]gjp.MergeOption{gjp.PreferTheirs()},
+			merged("h3", `"b"`, `"none"`),
+			nil,
+			[]string{"/db/host", "/mode", "/opts"},
+		}, {
+			"custom resolver",
+			[]gjp.MergeOption{gjp.ResolveWith(func(conflict gjp.Conflict) (*gjp.Value, bool) {
+				if conflict.Ours == nil {
+					return conflict.Theirs, true
+				}
+				return nil, false
+			})},
+			merged("h1", `"b"`, `{"a":1}`),
+			[]string{"/db/host", "/opts"},
+			[]string{"/mode"},
+		},
+	}
+	paths := func(conflicts []gjp.Conflict) []string {
+		var ps []string
+		for _, conflict := range conflicts {
+			ps = append(ps, conflict.Path)
+		}
+		return ps
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			defer assert.SetFailable(t)()
+			merge, err := gjp.Merge3(base, ours, theirs, test.options...)
+			assert.Nil(err)
+			bs, err := merge.Document().MarshalJSON()
+			assert.Nil(err)
+			assert.Equal(string(bs), test.merged)
+			assert.Equal(merge.HasConflicts(), test.conflicts != nil)
+			assert.Equal(paths(merge.Conflicts()), test.conflicts)
+			assert.Equal(paths(merge.Resolved()), test.resolved)
+		})
+	}
+
+	// Conflict values and unchanged input.
+	merge, err := gjp.Merge3(base, ours, theirs)
+	assert.Nil(err)
+	conflict := merge.Conflicts()[1]
+	assert.Equal(conflict.Base.AsString(""), "a")
+	assert.Nil(conflict.Ours)
+	assert.Equal(conflict.Theirs.AsString(""), "b")
+	assert.Equal(base.Length("list"), 4)
+	assert.Equal(ours.ValueAt("db/host").AsString(""), "h2")
+	_, err = gjp.Merge3(base, ours, theirs, gjp.ResolveWith(nil))
+	assert.ErrorContains(err, "missing conflict resolver")
+
+	// Deletion of a null field.
+	merge, err = gjp.Merge3(parse(`{"a":null,"b":1}`), parse(`{"b":1}`), parse(`{"a":null,"b":1,"c":2}`))
+	assert.Nil(err)
+	bs, err := merge.Document().MarshalJSON()
+	assert.Nil(err)
+	assert.Equal(string(bs), `{"b":1,"c":2}`)
+	assert.False(merge.HasConflicts())
+
+	// Arrays changed on both sides are one unit.
+	arrays := []struct {
+		base     string
+		ours     string
+		theirs   string
+		merged   string
+		conflict string
+	}{
+		{`{"l":[1,2,3]}`, `{"l":[2,3]}`, `{"l":[1,2]}`, `{"l":[1,2,3]}`, "/l"},
+		{`[1,2,3]`, `[2,3]`, `[1,3]`, `[1,2,3]`, "/"},
+		{`{"l":[[1],[2]]}`, `{"l":[[1,3],[2]]}`, `{"l":[[1],[2,4]]}`, `{"l":[[1,3],[2,4]]}`, ""},
+		{`{"l":[1,2,3],"x":1}`, `{"l":[1,3],"x":1}`, `{"l":[1,3],"x":2}`, `{"l":[1,3],"x":2}`, ""},
+	}
+	for _, a := range arrays {
+		merge, err = gjp.Merge3(parse(a.base), parse(a.ours), parse(a.theirs))
+		assert.Nil(err)
+		bs, err = merge.Document().MarshalJSON()
+		assert.Nil(err)
+		assert.Equal(string(bs), a.merged, a.base)
+		if a.conflict == "" {
+			assert.False(merge.HasConflicts(), a.base)
+			continue
+		}
+		assert.Equal(paths(merge.Conflicts()), []string{a.conflict}, a.base)
+	}
+}
+
+// TestInfer tests the inference of document structures.
+func TestInfer(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	first, err := gjp.Parse([]byte(`{"id":1,"name":"Alice","tags":["a","bb"],
+		"address":{"city":"X"},"score":1.5,"note":null}`), "/")
+	assert.Nil(err)
+	second, err := gjp.Parse([]byte(`{"id":2,"name":"Bob","tags":[],"score":3,"note":"hi"}`), "/")
+	assert.Nil(err)
+
+	inference, err := first.Infer()
+	assert.Nil(err)
+	assert.Equal(inference.Samples(), 1)
+	pi, ok := inference.PathAt("address")
+	assert.True(ok)
+	assert.False(pi.Optional)
+	err = inference.Add(second)
+	assert.Nil(err)
+	assert.Equal(inference.Samples(), 2)
+
+	// Check paths.
+	paths := []string{}
+	for _, pi := range inference.Paths() {
+		paths = append(paths, pi.Path)
+	}
+	assert.Equal(paths, []string{"/", "/address", "/address/city", "/id", "/name", "/note", "/score", "/tags", "/tags/*"})
+	pi, ok = inference.PathAt("id")
+	assert.True(ok)
+	assert.Equal(pi.Kinds, []gjp.Kind{gjp.KindNumber})
+	assert.Equal(pi.Occurrences, 2)
+	assert.Equal(pi.Distinct, 2)
+	assert.True(pi.Integer)
+	assert.Equal(pi.MinNumber, 1.0)
+	assert.Equal(pi.MaxNumber, 2.0)
+	pi, _ = inference.PathAt("score")
+	assert.False(pi.Integer)
+	assert.Equal(pi.MinNumber, 1.5)
+	pi, _ = inference.PathAt("name")
+	assert.Equal(pi.MinLength, 3)
+	assert.Equal(pi.MaxLength, 5)
+	pi, _ = inference.PathAt("address")
+	assert.True(pi.Optional)
+	assert.False(pi.Nullable)
+	pi, _ = inference.PathAt("note")
+	assert.Equal(pi.Kinds, []gjp.Kind{gjp.KindNull, gjp.KindString})
+	assert.True(pi.Nullable)
+	assert.False(pi.Optional)
+	pi, _ = inference.PathAt("tags")
+	assert.Equal(pi.MinLength, 0)
+	assert.Equal(pi.MaxLength, 2)
+	pi, _ = inference.PathAt("tags/*")
+	assert.Equal(pi.Occurrences, 2)
+	assert.Equal(pi.MaxLength, 2)
+	_, ok = inference.PathAt("tags/0")
+	assert.False(ok)
+
+	// Merge inferences.
+	other, err := second.Infer()
+	assert.Nil(err)
+	err = other.Merge(inference)
+	assert.Nil(err)
+	assert.Equal(other.Samples(), 3)
+	pi, _ = other.PathAt("name")
+	assert.Equal(pi.Occurrences, 3)
+	assert.Equal(pi.Distinct, 2)
+	err = other.Merge(gjp.NewInference("::"))
+	assert.ErrorContains(err, "different separators")
+
+	// Check schema.
+	schema, err := inference.Schema()
+	assert.Nil(err)
+	assert.Equal(schema.ValueAt("$schema").AsString(""), gjp.SchemaDraft)
+	assert.Equal(schema.ValueAt("type").AsString(""), "object")
+	assert.Equal(schema.ValueAt("required").AsStringSlice(nil), []string{"id", "name", "note", "score", "tags"})
+	assert.Equal(schema.ValueAt("properties/id/type").AsString(""), "integer")
+	assert.Equal(schema.ValueAt("properties/score/type").AsString(""), "number")
+	assert.Equal(schema.ValueAt("properties/score/maximum").AsFloat64(0), 3.0)
+	assert.Equal(schema.ValueAt("properties/note/type").AsStringSlice(nil), []string{"null", "string"})
+	assert.Equal(schema.ValueAt("properties/name/minLength").AsInt(0), 3)
+	assert.Equal(schema.ValueAt("properties/tags/maxItems").AsInt(0), 2)
+	assert.Equal(schema.ValueAt("properties/tags/items/type").AsString(""), "string")
+	assert.Equal(schema.ValueAt("properties/address/required/0").AsString(""), "city")
+}
+
 // TestString tests retrieving values as strings.
 func TestString(t *testing.T) {
 	assert := asserts.NewTesting(t, asserts.FailStop)
@@ -269,6 +747,65 @@ func TestBool(t *testing.T) {
 	assert.Equal(bv, true)
 }
 
+// TestValueInspection tests the inspection and nested access of values.
+func TestValueInspection(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	bs, _ := createDocument(assert)
+
+	doc, err := gjp.Parse(bs, "/")
+	assert.Nil(err)
+	tests := []struct {
+		path   string
+		kind   gjp.Kind
+		length int
+	}{
+		{"/", gjp.KindObject, 4},
+		{"/A", gjp.KindString, 1},
+		{"/B", gjp.KindArray, 3},
+		{"/B/0/B", gjp.KindNumber, 1},
+		{"/B/0/C", gjp.KindBool, 1},
+		{"/B/2/S", gjp.KindNull, 1},
+		{"/X", gjp.KindNull, -1},
+	}
+	for _, test := range tests {
+		v := doc.ValueAt(test.path)
+		assert.Equal(v.Kind(), test.kind, test.path)
+		assert.Equal(v.Len(), test.length, test.path)
+		assert.Equal(doc.Length(test.path), test.length, test.path)
+	}
+	assert.Equal(gjp.KindArray.String(), "array")
+
+	// Nested access.
+	b := doc.ValueAt("/B")
+	assert.Equal(b.ValueAt("1/D/A").AsString(""), "Level Three - 1")
+	assert.Equal(b.ValueAt("/0/S/1").AsString(""), "green")
+	assert.True(b.ValueAt("5").IsUndefined())
+	assert.True(doc.ValueAt("/X").ValueAt("Y").IsUndefined())
+	doc, err = gjp.Parse(bs, "::")
+	assert.Nil(err)
+	assert.Equal(doc.ValueAt("B::1").ValueAt("D::A").AsString(""), "Level Three - 1")
+
+	// Slices and maps.
+	ss := doc.ValueAt("B::0::S").AsStringSlice(nil)
+	assert.Equal(ss, []string{"red", "green", "1", "2.2", "true"})
+	ss = doc.ValueAt("A").AsStringSlice([]string{"default"})
+	assert.Equal(ss, []string{"default"})
+	m := doc.ValueAt("B::0::D").AsMap(nil)
+	assert.Length(m, 2)
+	assert.Equal(m["B"].AsFloat64(0.0), 10.1)
+	m = doc.ValueAt("B::0").AsMap(nil)
+	assert.Equal(m["D"].ValueAt("A").AsString(""), "Level Three - 0")
+	m = doc.ValueAt("B").AsMap(nil)
+	assert.Nil(m)
+
+	// JSON strings.
+	assert.Equal(doc.ValueAt("B::1::D").String(), `{"A":"Level Three - 1","B":20.2}`)
+	assert.Equal(doc.ValueAt("B::1::S").String(), `["orange","blue","white"]`)
+	assert.Equal(doc.ValueAt("A").String(), "Level One")
+	ss = doc.ValueAt("B::1").ValueAt("D").AsMap(nil)["A"].AsStringSlice(nil)
+	assert.Nil(ss)
+}
+
 // TestQuery tests querying a document.
 func TestQuery(t *testing.T) {
 	assert := asserts.NewTesting(t, asserts.FailStop)
@@ -307,6 +844,70 @@ func TestQuery(t *testing.T) {
 	assert.Equal(pvs[0].Value.AsString(""), "Level One")
 }
 
+// TestQueryExpr tests querying a document with expressions.
+func TestQueryExpr(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	bs, _ := createDocument(assert)
+
+	doc, err := gjp.Parse(bs, "/")
+	assert.Nil(err)
+	tests := []struct {
+		expr   string
+		length int
+		first  string
+		err    string
+	}{
+		{"/B/*/A", 3, "/B/0/A", ""},
+		{"/B/**/A", 6, "/B/0/A", ""},
+		{"**/A", 7, "/A", ""},
+		{"/B/*/S/*", 8, "/B/0/S/0", ""},
+		{"/B/*", 0, "", ""},
+		{"~^/B/[01]/D/.*$", 4, "/B/0/D/A", ""},
+		{"/B/*/B > 150", 2, "/B/1/B", ""},
+		{"/B/*/B <= 200", 2, "/B/0/B", ""},
+		{"/B/**/B == 20.2", 1, "/B/1/D/B", ""},
+		{"/B/*/S/* >= 1", 2, "/B/0/S/2", ""},
+		{"**/A == \"Level Two - 1\"", 1, "/B/1/A", ""},
+		{"**/A != \"Level Two - 1\"", 6, "/A", ""},
+		{"**/A =~ ^Level.Three", 3, "/B/0/D/A", ""},
+		{"~/S/ =~ ^(red|blue)$", 2, "/B/0/S/0", ""},
+		{"/B/** type == bool", 3, "/B/0/C", ""},
+		{"type == string", 16, "/A", ""},
+		{"type != string", 11, "/B/0/B", ""},
+		{"", 0, "", "empty query"},
+		{"/A ==", 0, "", "invalid operator"},
+		{"/A <> 1", 0, "", "invalid operator"},
+		{"/A == \"foo", 0, "", "unterminated quote"},
+		{"~[ == 1", 0, "", "invalid regular expression"},
+		{"/A =~ [", 0, "", "invalid regular expression"},
+		{"type == foo", 0, "", "invalid type"},
+		{"type > string", 0, "", "invalid type operator"},
+		{"/A type == string too", 0, "", "invalid number of query tokens"},
+	}
+	for _, test := range tests {
+		pvs, err := doc.QueryExpr(test.expr)
+		if test.err != "" {
+			assert.ErrorMatch(err, ".*"+test.err+".*", test.expr)
+			continue
+		}
+		assert.Nil(err, test.expr)
+		assert.Length(pvs, test.length, test.expr)
+		if test.length > 0 {
+			assert.Equal(pvs[0].Path, test.first, test.expr)
+		}
+	}
+
+	// Combining matchers.
+	re, err := gjp.MatchRegexp("/S/")
+	assert.Nil(err)
+	vm, err := gjp.MatchValue("!=", "red")
+	assert.Nil(err)
+	pvs, err := doc.QueryWith(gjp.MatchPath("/B/0/**", "/"), re, vm)
+	assert.Nil(err)
+	assert.Length(pvs, 4)
+	assert.Equal(pvs[0].Value.AsString(""), "green")
+}
+
 // TestBuilding tests the creation of documents.
 func TestBuilding(t *testing.T) {
 	assert := asserts.NewTesting(t, asserts.FailStop)
@@ -360,6 +961,143 @@ func TestBuilding(t *testing.T) {
 	assert.Equal(iv, 2)
 }
 
+// TestSettingTrees tests the setting of objects and arrays.
+func TestSettingTrees(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	doc := gjp.NewDocument("/")
+	err := doc.SetTreeAt("/a", map[string]interface{}{
+		"b": 1,
+		"c": []string{"x", "y"},
+	}, gjp.SetKeep)
+	assert.Nil(err)
+	assert.Equal(doc.ValueAt("/a/b").AsInt(0), 1)
+	assert.Equal(doc.ValueAt("/a/c/1").AsString(""), "y")
+	err = doc.SetTreeAt("/a/d", &levelThree{"three", 3.3}, gjp.SetKeep)
+	assert.Nil(err)
+	assert.Equal(doc.ValueAt("/a/d/A").AsString(""), "three")
+	assert.Equal(doc.ValueAt("/a/d/B").AsFloat64(0.0), 3.3)
+	err = doc.SetTreeAt("/a/e/0", []int{1, 2, 3}, gjp.SetKeep)
+	assert.Nil(err)
+	assert.Equal(doc.Length("/a/e/0"), 3)
+	err = doc.SetTreeAt("/a/b", 2, gjp.SetValues)
+	assert.Nil(err)
+	assert.Equal(doc.ValueAt("/a/b").AsInt(0), 2)
+
+	// Modes protect existing content.
+	err = doc.SetTreeAt("/a/b", 3, gjp.SetKeep)
+	assert.ErrorMatch(err, ".*already contains a value.*")
+	err = doc.SetTreeAt("/a/c", []string{"z"}, gjp.SetValues)
+	assert.ErrorMatch(err, ".*corrupt.*")
+	err = doc.SetTreeAt("/a/b/x", 4, gjp.SetValues)
+	assert.ErrorMatch(err, ".*corrupt.*")
+	err = doc.SetTreeAt("/a", "foo", gjp.SetKeep)
+	assert.ErrorMatch(err, ".*already contains a value.*")
+	err = doc.SetTreeAt("/a/x", func() {}, gjp.SetOverwrite)
+	assert.ErrorMatch(err, ".*cannot marshal value.*")
+	assert.Equal(doc.ValueAt("/a/b").AsInt(0), 2)
+	assert.Equal(doc.Length("/a/c"), 2)
+
+	// Overwriting.
+	err = doc.SetTreeAt("/a/c", []string{"z"}, gjp.SetOverwrite)
+	assert.Nil(err)
+	assert.Equal(doc.Length("/a/c"), 1)
+	err = doc.SetTreeAt("/a/b/x", 4, gjp.SetOverwrite)
+	assert.Nil(err)
+	assert.Equal(doc.ValueAt("/a/b/x").AsInt(0), 4)
+	err = doc.SetTreeAt("/", map[string]int{"z": 1}, gjp.SetOverwrite)
+	assert.Nil(err)
+	assert.Equal(doc.Length("/"), 1)
+	assert.Equal(doc.ValueAt("/z").AsInt(0), 1)
+}
+
+// TestRemoving tests the removing of values.
+func TestRemoving(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	bs, _ := createDocument(assert)
+
+	doc, err := gjp.Parse(bs, "/")
+	assert.Nil(err)
+	err = doc.RemoveValueAt("/A")
+	assert.Nil(err)
+	assert.True(doc.ValueAt("/A").IsUndefined())
+	assert.Equal(doc.Length("/"), 3)
+	err = doc.RemoveValueAt("/B/0/S/1")
+	assert.Nil(err)
+	assert.Equal(doc.Length("/B/0/S"), 4)
+	assert.Equal(doc.ValueAt("/B/0/S/1").AsString(""), "1")
+	err = doc.RemoveValueAt("/B/1")
+	assert.Nil(err)
+	assert.Equal(doc.Length("/B"), 2)
+	assert.Equal(doc.ValueAt("/B/1/A").AsString(""), "Level Two - 2")
+
+	// Now provoke errors.
+	err = doc.RemoveValueAt("/X")
+	assert.ErrorMatch(err, ".*cannot remove value at '/X'.*")
+	err = doc.RemoveValueAt("/B/5")
+	assert.ErrorMatch(err, ".*invalid path part.*")
+	err = doc.RemoveValueAt("/B/0/A/X")
+	assert.ErrorMatch(err, ".*path is too long.*")
+	assert.Equal(doc.Length("/B"), 2)
+
+	// Values taken before keep their content.
+	doc, err = gjp.Parse([]byte(`{"a":[1,2,3]}`), "/")
+	assert.Nil(err)
+	a := doc.ValueAt("a")
+	err = doc.RemoveValueAt("a/0")
+	assert.Nil(err)
+	assert.Equal(a.String(), "[1,2,3]")
+	assert.Equal(doc.ValueAt("a").String(), "[2,3]")
+
+	// Remove the root.
+	err = doc.RemoveValueAt("/")
+	assert.Nil(err)
+	assert.True(doc.ValueAt("/").IsUndefined())
+}
+
+// TestMovingCopying tests the moving and copying of values and subtrees.
+func TestMovingCopying(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	bs, _ := createDocument(assert)
+
+	doc, err := gjp.Parse(bs, "/")
+	assert.Nil(err)
+	err = doc.MoveValueAt("/A", "/X/Y")
+	assert.Nil(err)
+	assert.True(doc.ValueAt("/A").IsUndefined())
+	assert.Equal(doc.ValueAt("/X/Y").AsString(""), "Level One")
+	err = doc.MoveValueAt("/B/0/D", "/D3")
+	assert.Nil(err)
+	assert.Equal(doc.ValueAt("/D3/B").AsFloat64(0.0), 10.1)
+	assert.Equal(doc.Length("/B/0"), 4)
+	err = doc.CopyValueAt("/B/1", "/B/3")
+	assert.Nil(err)
+	assert.Equal(doc.Length("/B"), 4)
+	assert.Equal(doc.ValueAt("/B/3/A").AsString(""), "Level Two - 1")
+
+	// Copies are independent.
+	err = doc.SetValueAt("/B/3/A", "Copy")
+	assert.Nil(err)
+	assert.Equal(doc.ValueAt("/B/1/A").AsString(""), "Level Two - 1")
+
+	// Now provoke errors, the document stays unchanged.
+	before, err := doc.MarshalJSON()
+	assert.Nil(err)
+	err = doc.MoveValueAt("/B", "/B/0/X")
+	assert.ErrorMatch(err, ".*into itself.*")
+	err = doc.MoveValueAt("/X/Z", "/Z")
+	assert.ErrorMatch(err, ".*cannot move value at '/X/Z'.*")
+	err = doc.MoveValueAt("/D3", "/B")
+	assert.ErrorMatch(err, ".*cannot move value to '/B'.*corrupt.*")
+	err = doc.CopyValueAt("/B/1", "/X/Y/Z")
+	assert.ErrorMatch(err, ".*cannot copy value to '/X/Y/Z'.*corrupt.*")
+	err = doc.CopyValueAt("/Q", "/R")
+	assert.ErrorMatch(err, ".*cannot copy value at '/Q'.*")
+	after, err := doc.MarshalJSON()
+	assert.Nil(err)
+	assert.Equal(after, before)
+}
+
 // TestMarshalJSON tests building a JSON document again.
 func TestMarshalJSON(t *testing.T) {
 	assert := asserts.NewTesting(t, asserts.FailStop)
@@ -384,6 +1122,294 @@ func TestMarshalJSON(t *testing.T) {
 	assert.Equal(bsOut, bsIn)
 }
 
+// TestUnmarshalJSON tests unmarshalling documents.
+func TestUnmarshalJSON(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	bs, lo := createDocument(assert)
+
+	// Direct unmarshalling.
+	var doc gjp.Document
+	err := json.Unmarshal(bs, &doc)
+	assert.Nil(err)
+	assert.Equal(doc.ValueAt("B/1/D/A").AsString(""), lo.B[1].D.A)
+
+	// Keep the separator.
+	sdoc := gjp.NewDocument("::")
+	err = sdoc.UnmarshalJSON(bs)
+	assert.Nil(err)
+	assert.Equal(sdoc.ValueAt("B::1::D::A").AsString(""), lo.B[1].D.A)
+
+	// Embedded in other types.
+	wrapper := struct {
+		Name string        `json:"name"`
+		Doc  *gjp.Document `json:"doc"`
+	}{}
+	err = json.Unmarshal([]byte(`{"name":"wrapped","doc":{"a":{"b":1}}}`), &wrapper)
+	assert.Nil(err)
+	assert.Equal(wrapper.Doc.ValueAt("a/b").AsInt(0), 1)
+
+	err = sdoc.UnmarshalJSON([]byte(`{"a":`))
+	assert.ErrorMatch(err, ".*cannot unmarshal document.*")
+}
+
+// TestBind tests binding subtrees into structs.
+func TestBind(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	bs, lo := createDocument(assert)
+
+	doc, err := gjp.Parse(bs, "/")
+	assert.Nil(err)
+	var lt levelTwo
+	err = doc.Bind("B/0", &lt)
+	assert.Nil(err)
+	assert.Equal(lt.A, lo.B[0].A)
+	assert.Equal(lt.D.B, lo.B[0].D.B)
+	assert.Equal(lt.S, lo.B[0].S)
+
+	tagged := struct {
+		Name  string  `json:"A"`
+		Value float64 `json:"B"`
+	}{}
+	err = doc.Bind("B/2/D", &tagged)
+	assert.Nil(err)
+	assert.Equal(tagged.Name, "Level Three - 2")
+	assert.Equal(tagged.Value, 30.3)
+
+	var ss []string
+	err = doc.Bind("B/1/S", &ss)
+	assert.Nil(err)
+	assert.Length(ss, 3)
+
+	err = doc.Bind("B/5", &lt)
+	assert.ErrorMatch(err, ".*cannot bind value at 'B/5'.*")
+	err = doc.Bind("B/0/S", &lt)
+	assert.ErrorMatch(err, ".*cannot bind value at 'B/0/S'.*")
+}
+
+// TestValueError tests the access to errors of values.
+func TestValueError(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	bs, _ := createDocument(assert)
+
+	doc, err := gjp.Parse(bs, "/")
+	assert.Nil(err)
+	v := doc.ValueAt("B/2/S")
+	assert.True(v.IsUndefined())
+	assert.False(v.IsError())
+	assert.NoError(v.Error())
+	v = doc.ValueAt("B/2/X")
+	assert.True(v.IsUndefined())
+	assert.True(v.IsError())
+	assert.ErrorMatch(v.Error(), ".*invalid path part: 'X'.*")
+	v = doc.ValueAt("A/X")
+	assert.ErrorMatch(v.Error(), ".*path is too long.*")
+	v = doc.ValueAt("B/3").ValueAt("A")
+	assert.ErrorMatch(v.Error(), ".*invalid path part: '3'.*")
+}
+
+// TestDJConversion tests the conversion between gjp and dj.
+func TestDJConversion(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	bs, lo := createDocument(assert)
+
+	doc, err := gjp.Parse(bs, "::")
+	assert.Nil(err)
+	djdoc, err := gjp.ToDJ(doc)
+	assert.Nil(err)
+	assert.Equal(djdoc.At("B", "#1", "D", "A").AsString(""), lo.B[1].D.A)
+	assert.Equal(djdoc.At(gjp.ToDJPath("B::2::B", "::")...).AsInt(0), 300)
+
+	doc, err = gjp.FromDJ(djdoc, "/")
+	assert.Nil(err)
+	assert.Equal(doc.ValueAt("B/0/S/1").AsString(""), "green")
+	out, err := doc.MarshalJSON()
+	assert.Nil(err)
+	assert.Equal(out, bs)
+
+	// Path translation.
+	assert.Equal(gjp.ToDJPath("a/0/b", "/"), []string{"a", "#0", "b"})
+	assert.Equal(gjp.ToDJPath("/a/-1/b/", "/"), []string{"a", "-1", "b"})
+	assert.Equal(gjp.ToDJPath("", "/"), []string{})
+	path, err := gjp.FromDJPath([]string{"a", "#0", "b"}, "/")
+	assert.Nil(err)
+	assert.Equal(path, "/a/0/b")
+	path, err = gjp.FromDJPath([]string{"a", "#12", "2021"}, "::")
+	assert.Nil(err)
+	assert.Equal(path, "::a::12::2021")
+	_, err = gjp.FromDJPath([]string{"#tag"}, "/")
+	assert.ErrorContains(err, "invalid array index: '#tag'")
+	_, err = gjp.FromDJPath([]string{"a", "#+"}, "/")
+	assert.ErrorContains(err, "invalid array index: '#+'")
+	_, err = gjp.FromDJPath([]string{"a", "#-1"}, "/")
+	assert.ErrorContains(err, "invalid array index: '#-1'")
+}
+
+// TestDJDocument tests the gjp API on top of dj.
+func TestDJDocument(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	bs, lo := createDocument(assert)
+
+	djdoc, err := dj.Parse(bytes.NewReader(bs))
+	assert.Nil(err)
+	var accessor gjp.Accessor = gjp.NewDJDocument(djdoc, "/")
+	assert.Implementor(&gjp.Document{}, &accessor)
+
+	// Reading.
+	assert.Equal(accessor.Length("B"), 3)
+	assert.Equal(accessor.Length("B/0/S"), 5)
+	assert.Equal(accessor.Length("A"), 1)
+	assert.Equal(accessor.Length("X"), -1)
+	assert.Equal(accessor.ValueAt("B/1/D/A").AsString(""), lo.B[1].D.A)
+	assert.Equal(accessor.ValueAt("B/0/B").AsInt(0), 100)
+	assert.Equal(accessor.ValueAt("B/0/S").Kind(), gjp.KindArray)
+	assert.True(accessor.ValueAt("B/9").IsError())
+	pvs, err := accessor.Query("/B/[01]/*A")
+	assert.Nil(err)
+	assert.Length(pvs, 4)
+	count := 0
+	err = accessor.Process(func(path string, value *gjp.Value) error {
+		count++
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(count, 27)
+
+	// Writing.
+	assert.Nil(accessor.SetValueAt("A", "changed"))
+	assert.Nil(accessor.SetValueAt("B/0/S/5", "appended"))
+	assert.Nil(accessor.SetValueAt("X/0/Y", 1))
+	assert.Nil(accessor.SetValueAt("X/1/Y", 2))
+	assert.Equal(djdoc.At("A").AsString(""), "changed")
+	assert.Equal(accessor.Length("B/0/S"), 6)
+	assert.Equal(djdoc.At("B", "#0", "S", "#5").AsString(""), "appended")
+	assert.Equal(accessor.ValueAt("X/1/Y").AsInt(0), 2)
+	err = accessor.SetValueAt("B/0/D", "corrupt")
+	assert.ErrorMatch(err, ".*corrupt.*")
+	err = accessor.SetValueAt("B/0/S/9", "too far")
+	assert.ErrorMatch(err, ".*cannot set value at 'B/0/S/9'.*")
+
+	// Marshalling and clearing.
+	out, err := accessor.MarshalJSON()
+	assert.Nil(err)
+	doc, err := gjp.Parse(out, "/")
+	assert.Nil(err)
+	assert.Equal(doc.ValueAt("X/0/Y").AsInt(0), 1)
+	accessor.Clear()
+	assert.True(accessor.ValueAt("").IsUndefined())
+
+	// Numerical and index like object keys.
+	bs = []byte(`{"years":{"2021":"ok","#tag":"tagged"},"list":[{"0":"zero"}]}`)
+	djdoc, err = dj.Parse(bytes.NewReader(bs))
+	assert.Nil(err)
+	accessor = gjp.NewDJDocument(djdoc, "/")
+	doc, err = gjp.Parse(bs, "/")
+	assert.Nil(err)
+	for _, path := range []string{"years/2021", "years/#tag", "list/0/0"} {
+		assert.Equal(accessor.ValueAt(path).AsString("-"), doc.ValueAt(path).AsString("+"), path)
+	}
+	assert.Equal(accessor.Length("years"), 2)
+	assert.Nil(accessor.SetValueAt("years/2022", "new"))
+	assert.Nil(accessor.SetValueAt("list/0/1", "one"))
+	assert.Equal(djdoc.At("years", "2022").AsString(""), "new")
+	assert.Equal(djdoc.At("list", "#0", "1").AsString(""), "one")
+}
+
+// TestSyncDocument tests the concurrent usage of a document.
+func TestSyncDocument(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	sd := gjp.NewSyncDocument(gjp.NewDocument("/"))
+	var accessor gjp.Accessor = sd
+	assert.NotNil(accessor)
+
+	// Concurrent writers and readers.
+	var wg sync.WaitGroup
+	for w := 0; w < 5; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				err := sd.SetValueAt(fmt.Sprintf("w%d/%d", w, i), i)
+				assert.Nil(err)
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				sd.ValueAt("w0").AsMap(nil)
+				_ = sd.Process(func(path string, value *gjp.Value) error {
+					return nil
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	for w := 0; w < 5; w++ {
+		assert.Equal(sd.Length(fmt.Sprintf("w%d", w)), 100)
+	}
+
+	// Transactions are atomic.
+	err := sd.Update(func(doc *gjp.Document) error {
+		if err := doc.SetValueAt("tx/a", 1); err != nil {
+			return err
+		}
+		return doc.SetValueAt("tx/a/b", 2)
+	})
+	assert.ErrorContains(err, "corrupts document")
+	assert.True(sd.ValueAt("tx").IsUndefined())
+	err = sd.Update(func(doc *gjp.Document) error {
+		if err := doc.SetValueAt("tx/a", 1); err != nil {
+			return err
+		}
+		return doc.MoveValueAt("w4", "tx/w")
+	})
+	assert.Nil(err)
+	assert.Equal(sd.ValueAt("tx/a").AsInt(0), 1)
+	assert.Equal(sd.Length("tx/w"), 100)
+	assert.True(sd.ValueAt("w4").IsUndefined())
+
+	// Readers don't wait for running updates.
+	err = sd.Update(func(doc *gjp.Document) error {
+		read := make(chan int)
+		go func() {
+			read <- sd.ValueAt("tx/a").AsInt(0)
+		}()
+		select {
+		case a := <-read:
+			assert.Equal(a, 1)
+		case <-time.After(time.Second):
+			assert.Fail("reader blocked by update")
+		}
+		return doc.SetValueAt("tx/a", 2)
+	})
+	assert.Nil(err)
+	assert.Equal(sd.ValueAt("tx/a").AsInt(0), 2)
+	err = sd.SetValueAt("tx/a", 1)
+	assert.Nil(err)
+
+	// Returned values and documents are independent.
+	value := sd.ValueAt("tx")
+	doc := sd.Document()
+	err = sd.RemoveValueAt("tx/a")
+	assert.Nil(err)
+	assert.Equal(value.ValueAt("a").AsInt(0), 1)
+	assert.Equal(doc.ValueAt("tx/a").AsInt(0), 1)
+	err = doc.SetValueAt("tx/b", 2)
+	assert.Nil(err)
+	assert.True(sd.ValueAt("tx/b").IsUndefined())
+	sd.Clear()
+	assert.True(sd.ValueAt("").IsUndefined())
+
+	// Zero value and missing document.
+	for _, sd := range []*gjp.SyncDocument{{}, gjp.NewSyncDocument(nil)} {
+		assert.True(sd.ValueAt("a").IsUndefined())
+		assert.Equal(sd.Length("a"), -1)
+		assert.Nil(sd.SetValueAt("a/b", 1))
+		assert.Equal(sd.ValueAt("a/b").AsInt(0), 1)
+		sd.Clear()
+		assert.True(sd.ValueAt("a").IsUndefined())
+	}
+}
+
 //--------------------
 // HELPERS
 //--------------------
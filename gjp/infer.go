@@ -0,0 +1,406 @@
+// Tideland Go Text - Generic JSON Processor
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package gjp // import "tideland.dev/go/text/gjp"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"math"
+	"sort"
+	"unicode/utf8"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// ElementsPart replaces the indices of array elements in the
+// paths of an inference, so all elements share one path.
+const ElementsPart = "*"
+
+// SchemaDraft is the JSON Schema version of inferred schemas.
+const SchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+//--------------------
+// PATH INFERENCE
+//--------------------
+
+// PathInference contains the observations for one path. Lengths
+// are those of strings and arrays, numbers only those of numbers.
+type PathInference struct {
+	Path        string
+	Kinds       []Kind
+	Occurrences int
+	Distinct    int
+	Nullable    bool
+	Optional    bool
+	Integer     bool
+	MinLength   int
+	MaxLength   int
+	MinNumber   float64
+	MaxNumber   float64
+}
+
+// pathStats collects the observations for one path.
+type pathStats struct {
+	parts       []string
+	kinds       map[Kind]int
+	occurrences int
+	values      map[string]struct{}
+	lengths     bool
+	minLength   int
+	maxLength   int
+	numbers     bool
+	fractions   bool
+	minNumber   float64
+	maxNumber   float64
+}
+
+// newPathStats creates empty statistics for the path parts.
+func newPathStats(parts []string) *pathStats {
+	return &pathStats{
+		parts:  parts,
+		kinds:  map[Kind]int{},
+		values: map[string]struct{}{},
+	}
+}
+
+// observe adds the raw value to the statistics.
+func (ps *pathStats) observe(raw interface{}) {
+	ps.occurrences++
+	kind := kindOf(raw)
+	ps.kinds[kind]++
+	switch kind {
+	case KindString:
+		ps.observeLength(utf8.RuneCountInString(raw.(string)))
+	case KindArray:
+		a, _ := isArray(raw)
+		ps.observeLength(len(a))
+		return
+	case KindObject:
+		return
+	case KindNumber:
+		f, _ := asNumber(raw)
+		ps.observeNumber(f, f != math.Trunc(f))
+	}
+	ps.values[jsonOf(raw)] = struct{}{}
+}
+
+// observeLength adds a length to the statistics.
+func (ps *pathStats) observeLength(l int) {
+	if !ps.lengths || l < ps.minLength {
+		ps.minLength = l
+	}
+	if !ps.lengths || l > ps.maxLength {
+		ps.maxLength = l
+	}
+	ps.lengths = true
+}
+
+// observeNumber adds a number to the statistics.
+func (ps *pathStats) observeNumber(f float64, fraction bool) {
+	if !ps.numbers || f < ps.minNumber {
+		ps.minNumber = f
+	}
+	if !ps.numbers || f > ps.maxNumber {
+		ps.maxNumber = f
+	}
+	ps.numbers = true
+	ps.fractions = ps.fractions || fraction
+}
+
+// merge adds the observations of the other statistics.
+func (ps *pathStats) merge(other *pathStats) {
+	ps.occurrences += other.occurrences
+	for kind, n := range other.kinds {
+		ps.kinds[kind] += n
+	}
+	for value := range other.values {
+		ps.values[value] = struct{}{}
+	}
+	if other.lengths {
+		ps.observeLength(other.minLength)
+		ps.observeLength(other.maxLength)
+	}
+	if other.numbers {
+		ps.observeNumber(other.minNumber, other.fractions)
+		ps.observeNumber(other.maxNumber, other.fractions)
+	}
+}
+
+//--------------------
+// INFERENCE
+//--------------------
+
+// Inference summarizes the structure of one or more sample documents.
+// The paths of array elements contain ElementsPart instead of the index.
+type Inference struct {
+	separator string
+	samples   int
+	stats     map[string]*pathStats
+}
+
+// NewInference creates an empty inference for documents using
+// the separator.
+func NewInference(separator string) *Inference {
+	return &Inference{
+		separator: separator,
+		stats:     map[string]*pathStats{},
+	}
+}
+
+// Infer walks over the document and returns the inference of
+// its structure.
+func (d *Document) Infer() (*Inference, error) {
+	i := NewInference(d.separator)
+	if err := i.Add(d); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// Add walks over the document and adds its observations as
+// one more sample.
+func (i *Inference) Add(d *Document) error {
+	sample := NewInference(i.separator)
+	sample.samples = 1
+	visited := map[string]struct{}{}
+	err := d.Process(func(path string, value *Value) error {
+		// Observe containers on the way once, then the value.
+		parts := splitPath(path, d.separator)
+		generalized := []string{}
+		node := d.root
+		for n, part := range parts {
+			concrete := pathify(parts[:n], i.separator)
+			if _, ok := visited[concrete]; !ok {
+				visited[concrete] = struct{}{}
+				sample.observe(generalized, node)
+			}
+			if _, ok := isArray(node); ok {
+				generalized = append(generalized, ElementsPart)
+			} else {
+				generalized = append(generalized, part)
+			}
+			var err error
+			node, err = valueAt(node, []string{part})
+			if err != nil {
+				return err
+			}
+		}
+		sample.observe(generalized, value.raw)
+		return nil
+	})
+	if err != nil {
+		return failure.Annotate(err, "cannot infer document")
+	}
+	return i.Merge(sample)
+}
+
+// Merge adds the observations of the other inference, e.g. of
+// further sample documents.
+func (i *Inference) Merge(other *Inference) error {
+	if i.separator != other.separator {
+		return failure.New("cannot merge inferences with different separators")
+	}
+	i.samples += other.samples
+	for path, ops := range other.stats {
+		ps, ok := i.stats[path]
+		if !ok {
+			ps = newPathStats(ops.parts)
+			i.stats[path] = ps
+		}
+		ps.merge(ops)
+	}
+	return nil
+}
+
+// Samples returns the number of inferred sample documents.
+func (i *Inference) Samples() int {
+	return i.samples
+}
+
+// Paths returns the inferences of all paths in the order of
+// ProcessSorted(), but containers before their content.
+func (i *Inference) Paths() []PathInference {
+	pis := make([]PathInference, 0, len(i.stats))
+	for _, ps := range i.sortedStats() {
+		pis = append(pis, i.pathInference(ps))
+	}
+	return pis
+}
+
+// PathAt returns the inference of the path. Array elements are
+// addressed with ElementsPart.
+func (i *Inference) PathAt(path string) (PathInference, bool) {
+	ps, ok := i.stats[pathify(splitPath(path, i.separator), i.separator)]
+	if !ok {
+		return PathInference{}, false
+	}
+	return i.pathInference(ps), true
+}
+
+// Schema returns the inferred JSON Schema as document.
+func (i *Inference) Schema() (*Document, error) {
+	schema := map[string]interface{}{
+		"$schema": SchemaDraft,
+	}
+	if root, ok := i.stats[pathify(nil, i.separator)]; ok {
+		for key, value := range i.schemaOf(root) {
+			schema[key] = value
+		}
+	}
+	root, err := normalizeValue(schema)
+	if err != nil {
+		return nil, failure.Annotate(err, "cannot create schema")
+	}
+	return &Document{
+		separator: i.separator,
+		root:      root,
+	}, nil
+}
+
+// observe adds the raw value at the generalized path parts.
+func (i *Inference) observe(parts []string, raw interface{}) {
+	path := pathify(parts, i.separator)
+	ps, ok := i.stats[path]
+	if !ok {
+		ps = newPathStats(append([]string{}, parts...))
+		i.stats[path] = ps
+	}
+	ps.observe(raw)
+}
+
+// pathInference creates the exported inference of the statistics.
+func (i *Inference) pathInference(ps *pathStats) PathInference {
+	pi := PathInference{
+		Path:        pathify(ps.parts, i.separator),
+		Occurrences: ps.occurrences,
+		Distinct:    len(ps.values),
+		Nullable:    ps.kinds[KindNull] > 0,
+		Optional:    i.isOptional(ps),
+		Integer:     ps.numbers && !ps.fractions,
+		MinLength:   ps.minLength,
+		MaxLength:   ps.maxLength,
+		MinNumber:   ps.minNumber,
+		MaxNumber:   ps.maxNumber,
+	}
+	for kind := KindNull; kind <= KindBool; kind++ {
+		if ps.kinds[kind] > 0 {
+			pi.Kinds = append(pi.Kinds, kind)
+		}
+	}
+	return pi
+}
+
+// isOptional checks if the path is a field not found in all
+// occurrences of its parent object.
+func (i *Inference) isOptional(ps *pathStats) bool {
+	l := len(ps.parts)
+	if l == 0 {
+		return false
+	}
+	parent, ok := i.stats[pathify(ps.parts[:l-1], i.separator)]
+	if !ok || parent.kinds[KindObject] == 0 {
+		return false
+	}
+	return ps.occurrences < parent.kinds[KindObject]
+}
+
+// sortedStats returns the statistics sorted by their paths.
+func (i *Inference) sortedStats() []*pathStats {
+	pss := make([]*pathStats, 0, len(i.stats))
+	for _, ps := range i.stats {
+		pss = append(pss, ps)
+	}
+	sort.Slice(pss, func(a, b int) bool {
+		return partsLess(pss[a].parts, pss[b].parts)
+	})
+	return pss
+}
+
+// children returns the statistics directly below the passed ones.
+func (i *Inference) children(ps *pathStats) []*pathStats {
+	children := []*pathStats{}
+	for _, cps := range i.sortedStats() {
+		if len(cps.parts) == len(ps.parts)+1 && isPrefix(ps.parts, cps.parts) {
+			children = append(children, cps)
+		}
+	}
+	return children
+}
+
+// schemaOf creates the JSON Schema of the statistics.
+func (i *Inference) schemaOf(ps *pathStats) map[string]interface{} {
+	schema := map[string]interface{}{}
+	types := []interface{}{}
+	for kind := KindNull; kind <= KindBool; kind++ {
+		if ps.kinds[kind] == 0 {
+			continue
+		}
+		switch kind {
+		case KindNumber:
+			if ps.fractions {
+				types = append(types, "number")
+			} else {
+				types = append(types, "integer")
+			}
+			schema["minimum"] = ps.minNumber
+			schema["maximum"] = ps.maxNumber
+		case KindBool:
+			types = append(types, "boolean")
+		case KindString:
+			types = append(types, "string")
+			schema["minLength"] = ps.minLength
+			schema["maxLength"] = ps.maxLength
+		default:
+			types = append(types, kind.String())
+		}
+	}
+	if len(types) == 1 {
+		schema["type"] = types[0]
+	} else {
+		schema["type"] = types
+	}
+	if ps.kinds[KindArray] > 0 {
+		schema["minItems"] = ps.minLength
+		schema["maxItems"] = ps.maxLength
+		if ps.kinds[KindString] > 0 {
+			// Lengths are mixed, so don't restrict them.
+			delete(schema, "minLength")
+			delete(schema, "maxLength")
+			delete(schema, "minItems")
+			delete(schema, "maxItems")
+		}
+	}
+	properties := map[string]interface{}{}
+	required := []interface{}{}
+	for _, cps := range i.children(ps) {
+		part := cps.parts[len(cps.parts)-1]
+		if part == ElementsPart && ps.kinds[KindArray] > 0 {
+			schema["items"] = i.schemaOf(cps)
+			continue
+		}
+		properties[part] = i.schemaOf(cps)
+		if !i.isOptional(cps) {
+			required = append(required, part)
+		}
+	}
+	if len(properties) > 0 {
+		schema["properties"] = properties
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// EOF
@@ -0,0 +1,368 @@
+// Tideland Go Text - Generic JSON Processor
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package gjp // import "tideland.dev/go/text/gjp"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sort"
+	"strconv"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// OPTIONS
+//--------------------
+
+// MergeOption defines a function setting an option for merging.
+type MergeOption func(m *Merge) error
+
+// ConflictResolver decides about a conflict. It returns the value
+// to use, nil for removing the path, and true if it resolved the
+// conflict. Otherwise the conflict stays unresolved.
+type ConflictResolver func(conflict Conflict) (*Value, bool)
+
+// ResolveWith lets the merging resolve conflicts with the resolver.
+func ResolveWith(resolver ConflictResolver) MergeOption {
+	return func(m *Merge) error {
+		if resolver == nil {
+			return failure.New("missing conflict resolver")
+		}
+		m.resolver = resolver
+		return nil
+	}
+}
+
+// PreferOurs lets the merging resolve all conflicts with our values.
+func PreferOurs() MergeOption {
+	return ResolveWith(func(conflict Conflict) (*Value, bool) {
+		return conflict.Ours, true
+	})
+}
+
+// PreferTheirs lets the merging resolve all conflicts with their values.
+func PreferTheirs() MergeOption {
+	return ResolveWith(func(conflict Conflict) (*Value, bool) {
+		return conflict.Theirs, true
+	})
+}
+
+//--------------------
+// MERGE
+//--------------------
+
+// Conflict describes a path changed differently in our and their
+// document. Base, Ours, or Theirs are nil if the path does not exist
+// in the according document.
+type Conflict struct {
+	Path   string
+	Base   *Value
+	Ours   *Value
+	Theirs *Value
+}
+
+// Merge contains the result of a three-way merge.
+type Merge struct {
+	merged    *Document
+	conflicts []Conflict
+	resolved  []Conflict
+	resolver  ConflictResolver
+}
+
+// Merge3 merges the changes of ours and theirs compared to the base
+// document. Changes at different paths are merged automatically, also
+// identical changes at the same path. Others are conflicts, here the
+// merged document keeps the base value unless the options contain a
+// resolution strategy. Arrays are merged as a whole, so changing the
+// same array in different ways leads to one conflict at the path of the
+// array, even if the changes are at different indices. All documents are
+// accessed with the separator of the base document and stay unchanged.
+func Merge3(base, ours, theirs *Document, options ...MergeOption) (*Merge, error) {
+	m := &Merge{}
+	for _, option := range options {
+		if err := option(m); err != nil {
+			return nil, err
+		}
+	}
+	separator := base.separator
+	ours = &Document{separator: separator, root: ours.root}
+	theirs = &Document{separator: separator, root: theirs.root}
+	ourChanges, err := changesOf(base, ours)
+	if err != nil {
+		return nil, failure.Annotate(err, "cannot compare our document")
+	}
+	theirChanges, err := changesOf(base, theirs)
+	if err != nil {
+		return nil, failure.Annotate(err, "cannot compare their document")
+	}
+	ourChanges = arrayUnits(base, ourChanges)
+	theirChanges = arrayUnits(base, theirChanges)
+	// Collect changes to apply and conflicts.
+	changes := mergeChanges{}
+	conflicting := [][]string{}
+	for _, ourParts := range ourChanges {
+		agreed := true
+		for _, theirParts := range relatedChanges(ourParts, theirChanges) {
+			if !agree(ours, theirs, ourParts, theirParts) {
+				conflicting = append(conflicting, shorterOf(ourParts, theirParts))
+				agreed = false
+			}
+		}
+		if agreed {
+			changes.add(ours, ourParts)
+		}
+	}
+	for _, theirParts := range theirChanges {
+		if len(relatedChanges(theirParts, ourChanges)) == 0 {
+			changes.add(theirs, theirParts)
+		}
+	}
+	// Create the conflicts and resolve them if wanted.
+	for _, parts := range reduceConflicts(conflicting) {
+		conflict := Conflict{
+			Path:   pathify(parts, separator),
+			Base:   existingValueAt(base, parts),
+			Ours:   existingValueAt(ours, parts),
+			Theirs: existingValueAt(theirs, parts),
+		}
+		if m.resolver != nil {
+			if value, ok := m.resolver(conflict); ok {
+				changes.addValue(value, parts)
+				m.resolved = append(m.resolved, conflict)
+				continue
+			}
+		}
+		m.conflicts = append(m.conflicts, conflict)
+	}
+	root, err := changes.apply(copyNode(base.root))
+	if err != nil {
+		return nil, failure.Annotate(err, "cannot merge documents")
+	}
+	m.merged = &Document{
+		separator: separator,
+		root:      root,
+	}
+	return m, nil
+}
+
+// Document returns the merged document.
+func (m *Merge) Document() *Document {
+	return m.merged
+}
+
+// HasConflicts returns true if unresolved conflicts exist.
+func (m *Merge) HasConflicts() bool {
+	return len(m.conflicts) > 0
+}
+
+// Conflicts returns the unresolved conflicts sorted by path.
+func (m *Merge) Conflicts() []Conflict {
+	return m.conflicts
+}
+
+// Resolved returns the conflicts resolved by the resolution
+// strategy sorted by path.
+func (m *Merge) Resolved() []Conflict {
+	return m.resolved
+}
+
+//--------------------
+// MERGE HELPERS
+//--------------------
+
+// mergeChanges collects the changes of a merge.
+type mergeChanges struct {
+	sets    []mergeSet
+	removes [][]string
+}
+
+// mergeSet is one value to set.
+type mergeSet struct {
+	parts []string
+	value interface{}
+}
+
+// add adds the change at the path taking the value of the document.
+func (mc *mergeChanges) add(doc *Document, parts []string) {
+	mc.addValue(existingValueAt(doc, parts), parts)
+}
+
+// addValue adds the setting of the value or the removal in case
+// of nil.
+func (mc *mergeChanges) addValue(value *Value, parts []string) {
+	if value == nil {
+		mc.removes = append(mc.removes, parts)
+		return
+	}
+	mc.sets = append(mc.sets, mergeSet{parts, copyNode(value.raw)})
+}
+
+// apply applies the changes to the root. Values are set first in path
+// order, so arrays grow in the right order, then the removals follow in
+// reverse order, so array indices stay valid. Removals of paths already
+// gone with other changes are ignored.
+func (mc *mergeChanges) apply(root interface{}) (interface{}, error) {
+	sort.SliceStable(mc.sets, func(i, j int) bool {
+		return partsLess(mc.sets[i].parts, mc.sets[j].parts)
+	})
+	sort.SliceStable(mc.removes, func(i, j int) bool {
+		return partsLess(mc.removes[j], mc.removes[i])
+	})
+	var err error
+	for _, set := range mc.sets {
+		root, err = setChildValueAt(root, set.value, set.parts, SetOverwrite)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, parts := range mc.removes {
+		if _, err := valueAt(root, parts); err != nil {
+			continue
+		}
+		root, err = removeValueAt(root, parts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// changesOf returns the paths of the changes between base and other.
+// Paths only differing by the content of objects or arrays are skipped,
+// as their content is covered by the paths below. Missing paths differ
+// from null values, so their removals are changes too.
+func changesOf(base, other *Document) ([][]string, error) {
+	d, err := newDiff(base, other, []CompareOption{DistinguishMissing()})
+	if err != nil {
+		return nil, err
+	}
+	changes := [][]string{}
+	for _, path := range d.Differences() {
+		parts := splitPath(path, base.separator)
+		baseRaw, baseErr := valueAt(base.root, parts)
+		otherRaw, otherErr := valueAt(other.root, parts)
+		if baseErr == nil && otherErr == nil {
+			baseKind := kindOf(baseRaw)
+			if (baseKind == KindObject || baseKind == KindArray) && baseKind == kindOf(otherRaw) {
+				continue
+			}
+		}
+		changes = append(changes, parts)
+	}
+	return changes, nil
+}
+
+// arrayUnits replaces the changes inside of arrays of the base by the
+// paths of the innermost arrays, so each array is merged as one unit.
+// Duplicates are removed.
+func arrayUnits(base *Document, changes [][]string) [][]string {
+	units := [][]string{}
+	seen := map[string]struct{}{}
+	for _, parts := range changes {
+		for i := len(parts) - 1; i >= 0; i-- {
+			raw, err := valueAt(base.root, parts[:i])
+			if err != nil {
+				continue
+			}
+			if _, ok := isArray(raw); ok {
+				parts = parts[:i]
+				break
+			}
+		}
+		key := pathify(parts, base.separator)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		units = append(units, parts)
+	}
+	return units
+}
+
+// relatedChanges returns the changes at the same path, above, or below.
+func relatedChanges(parts []string, changes [][]string) [][]string {
+	related := [][]string{}
+	for _, change := range changes {
+		if isPrefix(parts, change) || isPrefix(change, parts) {
+			related = append(related, change)
+		}
+	}
+	return related
+}
+
+// agree checks if both documents contain the same at both paths.
+func agree(ours, theirs *Document, parts, other []string) bool {
+	d := &Diff{}
+	for _, check := range [][]string{parts, other} {
+		ourRaw, ourErr := valueAt(ours.root, check)
+		theirRaw, theirErr := valueAt(theirs.root, check)
+		if (ourErr == nil) != (theirErr == nil) {
+			return false
+		}
+		if ourErr == nil && !d.equal(ourRaw, theirRaw) {
+			return false
+		}
+	}
+	return true
+}
+
+// reduceConflicts sorts the conflicting paths and removes duplicates
+// as well as paths below other conflicting paths.
+func reduceConflicts(conflicting [][]string) [][]string {
+	sort.SliceStable(conflicting, func(i, j int) bool {
+		return partsLess(conflicting[i], conflicting[j])
+	})
+	reduced := [][]string{}
+	for _, parts := range conflicting {
+		if len(reduced) > 0 && isPrefix(reduced[len(reduced)-1], parts) {
+			continue
+		}
+		reduced = append(reduced, parts)
+	}
+	return reduced
+}
+
+// existingValueAt returns the value at the path or nil if
+// it does not exist.
+func existingValueAt(doc *Document, parts []string) *Value {
+	raw, err := valueAt(doc.root, parts)
+	if err != nil {
+		return nil
+	}
+	return &Value{raw, doc.separator, nil}
+}
+
+// shorterOf returns the shorter of both paths.
+func shorterOf(parts, other []string) []string {
+	if len(other) < len(parts) {
+		return other
+	}
+	return parts
+}
+
+// partsLess compares paths part by part. Numerical parts are
+// compared as numbers, so array elements are in index order.
+func partsLess(parts, other []string) bool {
+	for i := 0; i < len(parts) && i < len(other); i++ {
+		if parts[i] == other[i] {
+			continue
+		}
+		pi, perr := strconv.Atoi(parts[i])
+		oi, oerr := strconv.Atoi(other[i])
+		if perr == nil && oerr == nil {
+			return pi < oi
+		}
+		return parts[i] < other[i]
+	}
+	return len(parts) < len(other)
+}
+
+// EOF
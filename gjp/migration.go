@@ -0,0 +1,276 @@
+// Tideland Go Text - Generic JSON Processor
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package gjp // import "tideland.dev/go/text/gjp"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"tideland.dev/go/text/dj"
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// ACCESSOR
+//--------------------
+
+// Accessor describes the API of a Document. It is implemented by
+// Document and DJDocument, so code can be migrated to dj incrementally.
+type Accessor interface {
+	// Length returns the number of elements for the given path.
+	Length(path string) int
+
+	// SetValueAt sets the value at the given path.
+	SetValueAt(path string, value interface{}) error
+
+	// ValueAt returns the addressed value.
+	ValueAt(path string) *Value
+
+	// Clear removes the so far build document data.
+	Clear()
+
+	// Query allows to find pathes matching a given pattern.
+	Query(pattern string) (PathValues, error)
+
+	// Process iterates over a document and processes its values.
+	Process(processor ValueProcessor) error
+
+	// MarshalJSON implements json.Marshaler.
+	MarshalJSON() ([]byte, error)
+}
+
+//--------------------
+// CONVERSION
+//--------------------
+
+// ToDJ converts a document into a dj document.
+func ToDJ(d *Document) (*dj.Document, error) {
+	bs, err := d.MarshalJSON()
+	if err != nil {
+		return nil, failure.Annotate(err, "cannot convert document")
+	}
+	doc, err := dj.Parse(bytes.NewReader(bs))
+	if err != nil {
+		return nil, failure.Annotate(err, "cannot convert document")
+	}
+	return doc, nil
+}
+
+// FromDJ converts a dj document into a document using the
+// given separator.
+func FromDJ(doc *dj.Document, separator string) (*Document, error) {
+	bs, err := doc.MarshalJSON()
+	if err != nil {
+		return nil, failure.Annotate(err, "cannot convert document")
+	}
+	return Parse(bs, separator)
+}
+
+// ToDJPath translates a separator based path like "a/0/b" into the
+// keys of a dj path like ["a", "#0", "b"]. As the translation is done
+// without a document all numerical parts are treated as array indices.
+func ToDJPath(path, separator string) []string {
+	parts := splitPath(path, separator)
+	for i, part := range parts {
+		if index, ok := pathIndex(part); ok {
+			parts[i] = "#" + strconv.Itoa(index)
+		}
+	}
+	return parts
+}
+
+// FromDJPath translates the keys of a dj path like ["a", "#0", "b"]
+// into a separator based path like "/a/0/b". Keys starting with "#"
+// have to be non-negative array indices, as other ones like "#-1"
+// or "#+" cannot be expressed by a path.
+func FromDJPath(keys []string, separator string) (string, error) {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		if !strings.HasPrefix(key, "#") {
+			parts[i] = key
+			continue
+		}
+		if _, ok := pathIndex(key[1:]); !ok {
+			return "", failure.New("invalid array index: '%s'", key)
+		}
+		parts[i] = key[1:]
+	}
+	return pathify(parts, separator), nil
+}
+
+// pathIndex returns the part as array index if it is a
+// non-negative number.
+func pathIndex(part string) (int, bool) {
+	index, err := strconv.Atoi(part)
+	if err != nil || index < 0 {
+		return 0, false
+	}
+	return index, true
+}
+
+//--------------------
+// DJ DOCUMENT
+//--------------------
+
+// DJDocument implements the Accessor on top of a dj document. Other
+// than with a Document setting array elements is only possible for
+// existing indices or by appending with the index of the array length.
+type DJDocument struct {
+	separator string
+	doc       *dj.Document
+}
+
+// NewDJDocument wraps the dj document using the separator for
+// the paths.
+func NewDJDocument(doc *dj.Document, separator string) *DJDocument {
+	return &DJDocument{
+		separator: separator,
+		doc:       doc,
+	}
+}
+
+// DJ returns the wrapped dj document.
+func (dd *DJDocument) DJ() *dj.Document {
+	return dd.doc
+}
+
+// Length returns the number of elements for the given path.
+func (dd *DJDocument) Length(path string) int {
+	v := dd.doc.At(dd.djPath(path, false)...)
+	if v.IsError() {
+		return -1
+	}
+	switch v.Type() {
+	case dj.NodeTypeObject, dj.NodeTypeArray:
+		return v.Len()
+	}
+	return 1
+}
+
+// SetValueAt sets the value at the given path. Like with a Document
+// objects and arrays won't be replaced.
+func (dd *DJDocument) SetValueAt(path string, value interface{}) error {
+	raw, err := normalizeValue(value)
+	if err != nil {
+		return err
+	}
+	current := dd.doc.At(dd.djPath(path, false)...)
+	if !current.IsError() && (current.Type() == dj.NodeTypeObject || current.Type() == dj.NodeTypeArray) {
+		return failure.New("setting value corrupts document")
+	}
+	keys := dd.djPath(path, true)
+	if err := dd.doc.SetAt(raw, keys...); err != nil {
+		return failure.Annotate(err, "cannot set value at '%s'", path)
+	}
+	return nil
+}
+
+// ValueAt returns the addressed value.
+func (dd *DJDocument) ValueAt(path string) *Value {
+	v := dd.doc.At(dd.djPath(path, false)...)
+	return &Value{rawOfDJ(v), dd.separator, v.Error()}
+}
+
+// Clear removes the so far build document data.
+func (dd *DJDocument) Clear() {
+	dd.doc = dj.New()
+}
+
+// Query allows to find pathes matching a given pattern.
+func (dd *DJDocument) Query(pattern string) (PathValues, error) {
+	return dd.document().Query(pattern)
+}
+
+// Process iterates over a document and processes its values.
+func (dd *DJDocument) Process(processor ValueProcessor) error {
+	return dd.document().Process(processor)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (dd *DJDocument) MarshalJSON() ([]byte, error) {
+	return dd.doc.MarshalJSON()
+}
+
+// document returns the current content as read-only Document.
+func (dd *DJDocument) document() *Document {
+	return &Document{
+		separator: dd.separator,
+		root:      rawOfDJ(dd.doc.Root()),
+	}
+}
+
+// djPath translates the path into the keys of a dj path. Other than
+// ToDJPath it looks at the nodes on the way, so numerical parts are
+// only array indices for arrays and for missing nodes, which are
+// created as arrays like by a Document. When setting, indices at the
+// end of an array and the index 0 of a missing one append an element.
+func (dd *DJDocument) djPath(path string, setting bool) []string {
+	parts := splitPath(path, dd.separator)
+	keys := make([]string, len(parts))
+	current := dd.doc.Root()
+	for i, part := range parts {
+		keys[i] = part
+		index, ok := pathIndex(part)
+		switch {
+		case !ok:
+		case current.IsUndefined():
+			keys[i] = "#" + strconv.Itoa(index)
+			if setting && index == 0 {
+				keys[i] = "#+"
+			}
+		case current.Type() == dj.NodeTypeArray:
+			keys[i] = "#" + strconv.Itoa(index)
+			if setting && index == current.Len() {
+				keys[i] = "#+"
+			}
+		}
+		current = current.At(keys[i])
+	}
+	return keys
+}
+
+// rawOfDJ converts a dj value into its raw representation.
+func rawOfDJ(v *dj.Value) interface{} {
+	if v.IsError() {
+		return nil
+	}
+	switch v.Type() {
+	case dj.NodeTypeObject:
+		o := map[string]interface{}{}
+		_ = v.Do(func(key string, nv *dj.Value) error {
+			o[key] = rawOfDJ(nv)
+			return nil
+		})
+		return o
+	case dj.NodeTypeArray:
+		a := make([]interface{}, v.Len())
+		_ = v.Do(func(key string, nv *dj.Value) error {
+			index, err := strconv.Atoi(strings.TrimPrefix(key, "#"))
+			if err != nil {
+				return err
+			}
+			a[index] = rawOfDJ(nv)
+			return nil
+		})
+		return a
+	case dj.NodeTypeString:
+		return v.AsString("")
+	case dj.NodeTypeNumber:
+		return v.AsFloat64(0.0)
+	case dj.NodeTypeBool:
+		return v.AsBool(false)
+	}
+	return nil
+}
+
+// EOF
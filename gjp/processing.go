@@ -12,10 +12,11 @@ package gjp // import "tideland.dev/go/text/gjp"
 //--------------------
 
 import (
+	"encoding/json"
+	"sort"
 	"strconv"
 	"strings"
 
-	"tideland.dev/go/text/stringex"
 	"tideland.dev/go/trace/failure"
 )
 
@@ -23,11 +24,56 @@ import (
 // PROCESSING FUNCTIONS
 //--------------------
 
-// splitPath splits and cleans the path into parts.
+// splitPath splits the path into parts. Separators escaped with the
+// PathEscape are part of the keys. One leading and one trailing
+// separator are optional, empty parts between separators are empty
+// keys. So "/a//b" addresses the key "" inside of "a", and "/a//"
+// the key "" as last part.
 func splitPath(path, separator string) []string {
-	return stringex.SplitFilter(path, separator, func(part string) bool {
-		return part != ""
-	})
+	parts := []string{}
+	if separator == "" {
+		if path != "" {
+			parts = append(parts, path)
+		}
+		return parts
+	}
+	path = strings.TrimPrefix(path, separator)
+	if path == "" {
+		return parts
+	}
+	var part strings.Builder
+	for i := 0; i < len(path); {
+		switch {
+		case strings.HasPrefix(path[i:], PathEscape+separator):
+			part.WriteString(separator)
+			i += len(PathEscape) + len(separator)
+		case strings.HasPrefix(path[i:], PathEscape+PathEscape):
+			part.WriteString(PathEscape)
+			i += 2 * len(PathEscape)
+		case strings.HasPrefix(path[i:], separator):
+			parts = append(parts, part.String())
+			part.Reset()
+			i += len(separator)
+			if i == len(path) {
+				// Trailing separator.
+				return parts
+			}
+		default:
+			part.WriteByte(path[i])
+			i++
+		}
+	}
+	return append(parts, part.String())
+}
+
+// escapePart escapes the escape string and separators inside of
+// a path part.
+func escapePart(part, separator string) string {
+	part = strings.ReplaceAll(part, PathEscape, PathEscape+PathEscape)
+	if separator == "" {
+		return part
+	}
+	return strings.ReplaceAll(part, separator, PathEscape+separator)
 }
 
 // isValue checks if the raw is a value and returns it
@@ -67,9 +113,6 @@ func valueAt(node interface{}, parts []string) (interface{}, error) {
 	}
 	// Further access depends on part content node and type.
 	head, tail := ht(parts)
-	if head == "" {
-		return node, nil
-	}
 	if o, ok := isObject(node); ok {
 		// JSON object.
 		field, ok := o[head]
@@ -81,8 +124,8 @@ func valueAt(node interface{}, parts []string) (interface{}, error) {
 	if a, ok := isArray(node); ok {
 		// JSON array.
 		index, err := strconv.Atoi(head)
-		if err != nil || index >= len(a) {
-			return nil, failure.Annotate(err, "invalid path part: '%s'", head)
+		if err != nil || index < 0 || index >= len(a) {
+			return nil, failure.New("invalid path part: '%s'", head)
 		}
 		return valueAt(a[index], tail)
 	}
@@ -91,9 +134,13 @@ func valueAt(node interface{}, parts []string) (interface{}, error) {
 }
 
 // setValueAt sets the value at the path parts.
-func setValueAt(root, value interface{}, parts []string) (interface{}, error) {
+func setValueAt(root, value interface{}, parts []string, mode SetMode) (interface{}, error) {
+	if len(parts) == 0 {
+		// Set the root itself.
+		return setChildValueAt(root, value, parts, mode)
+	}
 	h, t := ht(parts)
-	return setNodeValueAt(root, value, h, t)
+	return setNodeValueAt(root, value, h, t, mode)
 }
 
 // ht retrieves head and tail from parts.
@@ -109,7 +156,7 @@ func ht(parts []string) (string, []string) {
 }
 
 // setNodeValueAt is used recursively by setValueAt().
-func setNodeValueAt(node, value interface{}, head string, tail []string) (interface{}, error) {
+func setNodeValueAt(node, value interface{}, head string, tail []string, mode SetMode) (interface{}, error) {
 	// Check for nil node first.
 	if node == nil {
 		return addNodeValueAt(value, head, tail)
@@ -117,58 +164,69 @@ func setNodeValueAt(node, value interface{}, head string, tail []string) (interf
 	// Otherwise it should be an object or an array.
 	if o, ok := isObject(node); ok {
 		// JSON object.
-		_, ok := isValue(o[head])
-		switch {
-		case !ok && len(tail) == 0:
-			return nil, failure.New("setting value corrupts document")
-		case ok && o[head] != nil && len(tail) > 0:
-			return nil, failure.New("setting value corrupts document")
-		case ok && len(tail) == 0:
-			o[head] = value
-		default:
-			h, t := ht(tail)
-			subnode, err := setNodeValueAt(o[head], value, h, t)
-			if err != nil {
-				return nil, err
-			}
-			o[head] = subnode
+		subnode, err := setChildValueAt(o[head], value, tail, mode)
+		if err != nil {
+			return nil, err
 		}
+		o[head] = subnode
 		return o, nil
 	}
 	if a, ok := isArray(node); ok {
 		// JSON array.
 		index, err := strconv.Atoi(head)
-		if err != nil {
+		if err != nil || index < 0 {
 			return nil, failure.New("invalid path part: '%s'", head)
 		}
 		a = ensureArray(a, index+1)
-		_, ok := isValue(a[index])
-		switch {
-		case !ok && len(tail) == 0:
-			return nil, failure.New("setting value corrupts document")
-		case ok && a[index] != nil && len(tail) > 0:
-			return nil, failure.New("setting value corrupts document")
-		case ok && len(tail) == 0:
-			a[index] = value
-		default:
-			h, t := ht(tail)
-			subnode, err := setNodeValueAt(a[index], value, h, t)
-			if err != nil {
-				return nil, err
-			}
-			a[index] = subnode
+		subnode, err := setChildValueAt(a[index], value, tail, mode)
+		if err != nil {
+			return nil, err
 		}
+		a[index] = subnode
 		return a, nil
 	}
 	return nil, failure.New("invalid path part: '%s'", head)
 }
 
-// addNodeValueAt is used recursively by setValueAt().
-func addNodeValueAt(value interface{}, head string, tail []string) (interface{}, error) {
-	// JSON value.
-	if head == "" {
+// setChildValueAt is used by setNodeValueAt() to set the value at
+// the tail of a child node depending on the mode. It returns the
+// new child node.
+func setChildValueAt(child, value interface{}, tail []string, mode SetMode) (interface{}, error) {
+	_, ok := isValue(child)
+	switch {
+	case len(tail) == 0 && child != nil && mode == SetKeep:
+		return nil, failure.New("path already contains a value")
+	case len(tail) == 0 && (ok || mode == SetOverwrite):
 		return value, nil
+	case len(tail) == 0:
+		return nil, failure.New("setting value corrupts document")
+	case ok && child != nil && mode == SetOverwrite:
+		h, t := ht(tail)
+		return addNodeValueAt(value, h, t)
+	case ok && child != nil:
+		return nil, failure.New("setting value corrupts document")
+	}
+	h, t := ht(tail)
+	return setNodeValueAt(child, value, h, t, mode)
+}
+
+// normalizeValue converts any JSON compatible value like maps,
+// slices, or structs with JSON tags into the generic representation
+// of parsed documents.
+func normalizeValue(value interface{}) (interface{}, error) {
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return nil, failure.Annotate(err, "cannot marshal value")
 	}
+	var raw interface{}
+	if err := json.Unmarshal(bs, &raw); err != nil {
+		return nil, failure.Annotate(err, "cannot unmarshal value")
+	}
+	return raw, nil
+}
+
+// addNodeValueAt is used recursively by setValueAt().
+func addNodeValueAt(value interface{}, head string, tail []string) (interface{}, error) {
 	index, err := strconv.Atoi(head)
 	if err != nil {
 		// JSON object.
@@ -200,6 +258,86 @@ func addNodeValueAt(value interface{}, head string, tail []string) (interface{},
 	return a, nil
 }
 
+// removeValueAt removes the value at the path parts and returns
+// the changed node.
+func removeValueAt(node interface{}, parts []string) (interface{}, error) {
+	if len(parts) == 0 {
+		// Remove the node itself.
+		return nil, nil
+	}
+	head, tail := ht(parts)
+	if o, ok := isObject(node); ok {
+		// JSON object.
+		field, ok := o[head]
+		if !ok {
+			return nil, failure.New("invalid path part: '%s'", head)
+		}
+		if len(tail) == 0 {
+			delete(o, head)
+			return o, nil
+		}
+		subnode, err := removeValueAt(field, tail)
+		if err != nil {
+			return nil, err
+		}
+		o[head] = subnode
+		return o, nil
+	}
+	if a, ok := isArray(node); ok {
+		// JSON array.
+		index, err := strconv.Atoi(head)
+		if err != nil || index < 0 || index >= len(a) {
+			return nil, failure.New("invalid path part: '%s'", head)
+		}
+		if len(tail) == 0 {
+			// Create a new array, values taken before keep their content.
+			na := make([]interface{}, 0, len(a)-1)
+			na = append(na, a[:index]...)
+			return append(na, a[index+1:]...), nil
+		}
+		subnode, err := removeValueAt(a[index], tail)
+		if err != nil {
+			return nil, err
+		}
+		a[index] = subnode
+		return a, nil
+	}
+	// Parts left but field value.
+	return nil, failure.New("path is too long")
+}
+
+// copyNode creates a deep copy of the node.
+func copyNode(node interface{}) interface{} {
+	if o, ok := isObject(node); ok {
+		co := make(map[string]interface{}, len(o))
+		for field, subnode := range o {
+			co[field] = copyNode(subnode)
+		}
+		return co
+	}
+	if a, ok := isArray(node); ok {
+		ca := make([]interface{}, len(a))
+		for index, subnode := range a {
+			ca[index] = copyNode(subnode)
+		}
+		return ca
+	}
+	return node
+}
+
+// isPrefix checks if the parts are a prefix of the other parts.
+func isPrefix(parts, other []string) bool {
+	if len(parts) > len(other) {
+		return false
+	}
+	for i, part := range parts {
+		if other[i] != part {
+			return false
+		}
+	}
+	return true
+}
+
 // ensureArray ensures the right len of an array.
 func ensureArray(a []interface{}, l int) []interface{} {
 	if len(a) >= l {
@@ -210,8 +348,9 @@ func ensureArray(a []interface{}, l int) []interface{} {
 	return b
 }
 
-// process processes node recursively.
-func process(node interface{}, parts []string, separator string, processor ValueProcessor) error {
+// process processes node recursively. If sorted is true the fields
+// of objects are processed in lexicographical order.
+func process(node interface{}, parts []string, separator string, sorted bool, processor ValueProcessor) error {
 	mkerr := func(err error, ps []string) error {
 		return failure.Annotate(err, "cannot process '%s'", pathify(ps, separator))
 	}
@@ -219,11 +358,18 @@ func process(node interface{}, parts []string, separator string, processor Value
 	if o, ok := isObject(node); ok {
 		if len(o) == 0 {
 			// Empty object.
-			return processor(pathify(parts, separator), &Value{o, nil})
+			return processor(pathify(parts, separator), &Value{o, separator, nil})
 		}
-		for field, subnode := range o {
+		fields := make([]string, 0, len(o))
+		for field := range o {
+			fields = append(fields, field)
+		}
+		if sorted {
+			sort.Strings(fields)
+		}
+		for _, field := range fields {
 			fieldparts := append(parts, field)
-			if err := process(subnode, fieldparts, separator, processor); err != nil {
+			if err := process(o[field], fieldparts, separator, sorted, processor); err != nil {
 				return mkerr(err, parts)
 			}
 		}
@@ -232,23 +378,33 @@ func process(node interface{}, parts []string, separator string, processor Value
 	if a, ok := isArray(node); ok {
 		if len(a) == 0 {
 			// Empty array.
-			return processor(pathify(parts, separator), &Value{a, nil})
+			return processor(pathify(parts, separator), &Value{a, separator, nil})
 		}
 		for index, subnode := range a {
 			indexparts := append(parts, strconv.Itoa(index))
-			if err := process(subnode, indexparts, separator, processor); err != nil {
+			if err := process(subnode, indexparts, separator, sorted, processor); err != nil {
 				return mkerr(err, parts)
 			}
 		}
 		return nil
 	}
 	// Reached a value at the end.
-	return processor(pathify(parts, separator), &Value{node, nil})
+	return processor(pathify(parts, separator), &Value{node, separator, nil})
 }
 
-// pathify creates a path out of parts and separator.
+// pathify creates a path out of parts and separator. The
+// parts are escaped.
 func pathify(parts []string, separator string) string {
-	return separator + strings.Join(parts, separator)
+	escaped := make([]string, len(parts))
+	for i, part := range parts {
+		escaped[i] = escapePart(part, separator)
+	}
+	path := separator + strings.Join(escaped, separator)
+	if len(parts) > 0 && parts[len(parts)-1] == "" {
+		// Trailing separator for a last empty key.
+		path += separator
+	}
+	return path
 }
 
 // EOF
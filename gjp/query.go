@@ -0,0 +1,296 @@
+// Tideland Go Text - Generic JSON Processor
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package gjp // import "tideland.dev/go/text/gjp"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"tideland.dev/go/text/stringex"
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// MATCHER
+//--------------------
+
+// Matcher decides if a path and its value match a query.
+type Matcher func(path string, value *Value) bool
+
+// MatchPath returns a matcher checking the path part by part. Each
+// part is compared like by stringex.Matches(), so "*" matches any
+// content of one part. Additionally "**" matches any number of parts.
+func MatchPath(pattern, separator string) Matcher {
+	patternParts := splitPath(pattern, separator)
+	return func(path string, value *Value) bool {
+		return partsMatch(patternParts, splitPath(path, separator))
+	}
+}
+
+// MatchRegexp returns a matcher checking the path with the
+// regular expression.
+func MatchRegexp(expr string) (Matcher, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, failure.Annotate(err, "invalid regular expression")
+	}
+	return func(path string, value *Value) bool {
+		return re.MatchString(path)
+	}, nil
+}
+
+// MatchValue returns a matcher comparing the value with the operand.
+// Valid operators are "==", "!=", "<", "<=", ">", ">=", and "=~" for
+// regular expressions. If the operand is a number the values are
+// compared numerically, otherwise as strings.
+func MatchValue(operator, operand string) (Matcher, error) {
+	if operator == "=~" {
+		re, err := regexp.Compile(operand)
+		if err != nil {
+			return nil, failure.Annotate(err, "invalid regular expression")
+		}
+		return func(path string, value *Value) bool {
+			_, ok := isValue(value.raw)
+			return ok && !value.IsUndefined() && re.MatchString(value.AsString(""))
+		}, nil
+	}
+	compare, err := comparison(operator)
+	if err != nil {
+		return nil, err
+	}
+	if f, err := strconv.ParseFloat(operand, 64); err == nil {
+		return func(path string, value *Value) bool {
+			vf, ok := asNumber(value.raw)
+			if !ok {
+				return false
+			}
+			switch {
+			case vf < f:
+				return compare(-1)
+			case vf > f:
+				return compare(1)
+			}
+			return compare(0)
+		}, nil
+	}
+	return func(path string, value *Value) bool {
+		if _, ok := isValue(value.raw); !ok || value.IsUndefined() {
+			return false
+		}
+		return compare(strings.Compare(value.AsString(""), operand))
+	}, nil
+}
+
+// MatchType returns a matcher comparing the type of the value with
+// the passed one. Valid operators are "==" and "!=", valid types are
+// "null", "object", "array", "string", "number", and "bool". Objects and
+// arrays are only found if they are empty, as only values are queried.
+func MatchType(operator, typ string) (Matcher, error) {
+	switch typ {
+	case "null", "object", "array", "string", "number", "bool":
+	default:
+		return nil, failure.New("invalid type: '%s'", typ)
+	}
+	switch operator {
+	case "==":
+		return func(path string, value *Value) bool {
+			return kindOf(value.raw).String() == typ
+		}, nil
+	case "!=":
+		return func(path string, value *Value) bool {
+			return kindOf(value.raw).String() != typ
+		}, nil
+	}
+	return nil, failure.New("invalid type operator: '%s'", operator)
+}
+
+//--------------------
+// QUERIES
+//--------------------
+
+// QueryWith returns all paths and values matching all of the passed
+// matchers. The result is sorted like by ProcessSorted().
+func (d *Document) QueryWith(matchers ...Matcher) (PathValues, error) {
+	pvs := PathValues{}
+	err := d.ProcessSorted(func(path string, value *Value) error {
+		for _, matcher := range matchers {
+			if !matcher(path, value) {
+				return nil
+			}
+		}
+		pvs = append(pvs, PathValue{
+			Path:  path,
+			Value: value,
+		})
+		return nil
+	})
+	return pvs, err
+}
+
+// QueryExpr parses the query expression and returns all matching
+// paths and values. The expression consists of an optional path pattern
+// and an optional predicate, e.g.
+//
+//	/users/*/age > 30
+//	/users/**/name =~ ^A
+//	~^/users/[0-9]+/name$ != "John Doe"
+//	/users/** type == string
+//	type != null
+//
+// Path patterns are matched like by MatchPath(), a leading "~" marks a
+// regular expression like by MatchRegexp(). The predicates are those of
+// MatchValue() and MatchType(). Operands containing spaces have to be
+// quoted.
+func (d *Document) QueryExpr(expr string) (PathValues, error) {
+	matchers, err := parseQuery(expr, d.separator)
+	if err != nil {
+		return nil, failure.Annotate(err, "invalid query '%s'", expr)
+	}
+	return d.QueryWith(matchers...)
+}
+
+//--------------------
+// QUERY HELPERS
+//--------------------
+
+// parseQuery parses a query expression into matchers.
+func parseQuery(expr, separator string) ([]Matcher, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	matchers := []Matcher{}
+	// Check for leading path pattern.
+	if len(tokens) == 1 || len(tokens) == 4 || (len(tokens) == 3 && tokens[0] != "type") {
+		pattern := tokens[0]
+		tokens = tokens[1:]
+		if strings.HasPrefix(pattern, "~") {
+			m, err := MatchRegexp(pattern[1:])
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, m)
+		} else {
+			matchers = append(matchers, MatchPath(pattern, separator))
+		}
+	}
+	// Check for predicate.
+	switch {
+	case len(tokens) == 0:
+		return matchers, nil
+	case len(tokens) == 3 && tokens[0] == "type":
+		m, err := MatchType(tokens[1], tokens[2])
+		if err != nil {
+			return nil, err
+		}
+		return append(matchers, m), nil
+	case len(tokens) == 2:
+		m, err := MatchValue(tokens[0], tokens[1])
+		if err != nil {
+			return nil, err
+		}
+		return append(matchers, m), nil
+	}
+	return nil, failure.New("invalid number of query tokens")
+}
+
+// tokenize splits the expression at whitespaces. Double quoted
+// tokens may contain whitespaces, the quotes will be removed.
+func tokenize(expr string) ([]string, error) {
+	tokens := []string{}
+	token := strings.Builder{}
+	quoted := false
+	inToken := false
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inToken = true
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if inToken {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				inToken = false
+			}
+		default:
+			token.WriteRune(r)
+			inToken = true
+		}
+	}
+	if quoted {
+		return nil, failure.New("unterminated quote")
+	}
+	if inToken {
+		tokens = append(tokens, token.String())
+	}
+	if len(tokens) == 0 {
+		return nil, failure.New("empty query")
+	}
+	return tokens, nil
+}
+
+// partsMatch checks if the path parts match the pattern parts.
+func partsMatch(patternParts, pathParts []string) bool {
+	if len(patternParts) == 0 {
+		return len(pathParts) == 0
+	}
+	if patternParts[0] == "**" {
+		for i := 0; i <= len(pathParts); i++ {
+			if partsMatch(patternParts[1:], pathParts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(pathParts) == 0 || !stringex.Matches(patternParts[0], pathParts[0], false) {
+		return false
+	}
+	return partsMatch(patternParts[1:], pathParts[1:])
+}
+
+// comparison returns a function checking the result of a comparison
+// for the given operator.
+func comparison(operator string) (func(c int) bool, error) {
+	switch operator {
+	case "==":
+		return func(c int) bool { return c == 0 }, nil
+	case "!=":
+		return func(c int) bool { return c != 0 }, nil
+	case "<":
+		return func(c int) bool { return c < 0 }, nil
+	case "<=":
+		return func(c int) bool { return c <= 0 }, nil
+	case ">":
+		return func(c int) bool { return c > 0 }, nil
+	case ">=":
+		return func(c int) bool { return c >= 0 }, nil
+	}
+	return nil, failure.New("invalid operator: '%s'", operator)
+}
+
+// asNumber returns the raw value as number if it is a number or a
+// string containing a number.
+func asNumber(raw interface{}) (float64, bool) {
+	switch tr := raw.(type) {
+	case int:
+		return float64(tr), true
+	case float64:
+		return tr, true
+	case string:
+		f, err := strconv.ParseFloat(tr, 64)
+		return f, err == nil
+	}
+	return 0.0, false
+}
+
+// EOF
@@ -0,0 +1,193 @@
+// Tideland Go Text - Generic JSON Processor
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package gjp // import "tideland.dev/go/text/gjp"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+//--------------------
+// DIFFERENCE KINDS
+//--------------------
+
+// DifferenceKind classifies a difference between two documents.
+type DifferenceKind int
+
+const (
+	// Added marks a path only existing in the second document.
+	Added DifferenceKind = iota + 1
+
+	// Removed marks a path only existing in the first document.
+	Removed
+
+	// Changed marks a path with different values of the same type.
+	Changed
+
+	// TypeChanged marks a path with values of different types.
+	TypeChanged
+)
+
+// String implements fmt.Stringer.
+func (dk DifferenceKind) String() string {
+	switch dk {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	case TypeChanged:
+		return "type-changed"
+	}
+	return "unknown"
+}
+
+//--------------------
+// REPORT
+//--------------------
+
+// Difference describes one classified difference of two documents.
+// First or Second are nil if the path does not exist in the according
+// document.
+type Difference struct {
+	Path   string
+	Kind   DifferenceKind
+	First  *Value
+	Second *Value
+}
+
+// Report returns the classified differences in the order of
+// Differences().
+func (d *Diff) Report() []Difference {
+	report := make([]Difference, 0, len(d.paths))
+	for _, path := range d.paths {
+		report = append(report, d.classify(path))
+	}
+	return report
+}
+
+// RenderText writes the report in a unified text format. Values of
+// the first document are prefixed with "-", those of the second one
+// with "+".
+func (d *Diff) RenderText(w io.Writer) error {
+	return d.render(w, false)
+}
+
+// RenderTerminal writes the report like RenderText() but colors the
+// lines for terminals. Values of the first document are red, those of
+// the second one green, and those with changed types yellow.
+func (d *Diff) RenderTerminal(w io.Writer) error {
+	return d.render(w, true)
+}
+
+// RenderJSON writes the report as JSON array of objects containing
+// the path, the kind, and the existing first and second values.
+func (d *Diff) RenderJSON(w io.Writer) error {
+	type jsonDifference struct {
+		Path   string           `json:"path"`
+		Kind   string           `json:"kind"`
+		First  *json.RawMessage `json:"first,omitempty"`
+		Second *json.RawMessage `json:"second,omitempty"`
+	}
+	rawOf := func(value *Value) *json.RawMessage {
+		if value == nil {
+			return nil
+		}
+		rm := json.RawMessage(jsonOf(value.raw))
+		return &rm
+	}
+	jds := []jsonDifference{}
+	for _, difference := range d.Report() {
+		jds = append(jds, jsonDifference{
+			Path:   difference.Path,
+			Kind:   difference.Kind.String(),
+			First:  rawOf(difference.First),
+			Second: rawOf(difference.Second),
+		})
+	}
+	return json.NewEncoder(w).Encode(jds)
+}
+
+// classify determines the kind of difference at the path.
+func (d *Diff) classify(path string) Difference {
+	difference := Difference{
+		Path: path,
+	}
+	firstRaw, firstErr := valueAt(d.first.root, splitPath(path, d.first.separator))
+	if firstErr == nil {
+		difference.First = &Value{firstRaw, d.first.separator, nil}
+	}
+	secondRaw, secondErr := valueAt(d.second.root, splitPath(path, d.second.separator))
+	if secondErr == nil {
+		difference.Second = &Value{secondRaw, d.second.separator, nil}
+	}
+	switch {
+	case firstErr != nil:
+		difference.Kind = Added
+	case secondErr != nil:
+		difference.Kind = Removed
+	case kindOf(firstRaw) != kindOf(secondRaw):
+		difference.Kind = TypeChanged
+	default:
+		difference.Kind = Changed
+	}
+	return difference
+}
+
+// render writes the report line by line, colored if wanted.
+func (d *Diff) render(w io.Writer, colored bool) error {
+	line := func(color, prefix, path string, value *Value, comment string) error {
+		reset := "\033[0m"
+		if !colored {
+			color = ""
+			reset = ""
+		}
+		_, err := fmt.Fprintf(w, "%s%s %s = %s%s%s\n", color, prefix, path, jsonOf(value.raw), comment, reset)
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "--- first\n+++ second\n"); err != nil {
+		return err
+	}
+	for _, difference := range d.Report() {
+		firstColor, secondColor := "\033[31m", "\033[32m"
+		firstComment, secondComment := "", ""
+		if difference.Kind == TypeChanged {
+			firstColor, secondColor = "\033[33m", "\033[33m"
+			firstComment = fmt.Sprintf(" (%s)", kindOf(difference.First.raw))
+			secondComment = fmt.Sprintf(" (%s)", kindOf(difference.Second.raw))
+		}
+		if difference.First != nil {
+			if err := line(firstColor, "-", difference.Path, difference.First, firstComment); err != nil {
+				return err
+			}
+		}
+		if difference.Second != nil {
+			if err := line(secondColor, "+", difference.Path, difference.Second, secondComment); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonOf returns the JSON representation of the raw value.
+func jsonOf(raw interface{}) string {
+	bs, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Sprintf("%v", raw)
+	}
+	return string(bs)
+}
+
+// EOF
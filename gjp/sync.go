@@ -0,0 +1,186 @@
+// Tideland Go Text - Generic JSON Processor
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package gjp // import "tideland.dev/go/text/gjp"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+//--------------------
+// SYNC DOCUMENT
+//--------------------
+
+// SyncDocument is a document safe for concurrent usage. It works
+// copy-on-write: each change is done on a copy of the document which
+// replaces the current one when done. So readers always see a consistent
+// state and are not blocked by writers, while values returned earlier are
+// never changed afterwards. In exchange each change costs a copy of the
+// document, so multiple changes should be combined using Update().
+// The zero value is an empty document using the DefaultSeparator.
+type SyncDocument struct {
+	mu  sync.Mutex
+	doc atomic.Value
+}
+
+// NewSyncDocument creates a synchronized document with a copy
+// of the passed one. Without a document it's empty.
+func NewSyncDocument(doc *Document) *SyncDocument {
+	sd := &SyncDocument{}
+	if doc == nil {
+		return sd
+	}
+	sd.doc.Store(&Document{
+		separator: doc.separator,
+		root:      copyNode(doc.root),
+	})
+	return sd
+}
+
+// Document returns a copy of the current document. Changes to it
+// don't affect the synchronized document.
+func (sd *SyncDocument) Document() *Document {
+	doc := sd.current()
+	return &Document{
+		separator: doc.separator,
+		root:      copyNode(doc.root),
+	}
+}
+
+// PathOf creates a path out of the parts using the separator of
+// the document. Separators inside of the parts are escaped.
+func (sd *SyncDocument) PathOf(parts ...string) string {
+	return sd.current().PathOf(parts...)
+}
+
+// Length returns the number of elements for the given path.
+func (sd *SyncDocument) Length(path string) int {
+	return sd.current().Length(path)
+}
+
+// ValueAt returns the addressed value.
+func (sd *SyncDocument) ValueAt(path string) *Value {
+	return sd.current().ValueAt(path)
+}
+
+// Query allows to find pathes matching a given pattern.
+func (sd *SyncDocument) Query(pattern string) (PathValues, error) {
+	return sd.current().Query(pattern)
+}
+
+// QueryExpr parses the query expression and returns all matching
+// paths and values.
+func (sd *SyncDocument) QueryExpr(expr string) (PathValues, error) {
+	return sd.current().QueryExpr(expr)
+}
+
+// Process iterates over the current state of the document and
+// processes its values. Changes during the processing are not seen.
+func (sd *SyncDocument) Process(processor ValueProcessor) error {
+	return sd.current().Process(processor)
+}
+
+// ProcessSorted iterates over the current state of the document and
+// processes its values in a deterministic order.
+func (sd *SyncDocument) ProcessSorted(processor ValueProcessor) error {
+	return sd.current().ProcessSorted(processor)
+}
+
+// Bind unmarshals the value or subtree at the given path into the
+// target like json.Unmarshal() does.
+func (sd *SyncDocument) Bind(path string, target interface{}) error {
+	return sd.current().Bind(path, target)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (sd *SyncDocument) MarshalJSON() ([]byte, error) {
+	return sd.current().MarshalJSON()
+}
+
+// SetValueAt sets the value at the given path.
+func (sd *SyncDocument) SetValueAt(path string, value interface{}) error {
+	return sd.Update(func(doc *Document) error {
+		return doc.SetValueAt(path, value)
+	})
+}
+
+// SetTreeAt sets any JSON compatible value at the given path.
+func (sd *SyncDocument) SetTreeAt(path string, value interface{}, mode SetMode) error {
+	return sd.Update(func(doc *Document) error {
+		return doc.SetTreeAt(path, value, mode)
+	})
+}
+
+// RemoveValueAt removes the value at the given path.
+func (sd *SyncDocument) RemoveValueAt(path string) error {
+	return sd.Update(func(doc *Document) error {
+		return doc.RemoveValueAt(path)
+	})
+}
+
+// MoveValueAt moves the value or subtree at path from to the path to.
+func (sd *SyncDocument) MoveValueAt(from, to string) error {
+	return sd.Update(func(doc *Document) error {
+		return doc.MoveValueAt(from, to)
+	})
+}
+
+// CopyValueAt copies the value or subtree at path from to the path to.
+func (sd *SyncDocument) CopyValueAt(from, to string) error {
+	return sd.Update(func(doc *Document) error {
+		return doc.CopyValueAt(from, to)
+	})
+}
+
+// Clear removes the so far build document data.
+func (sd *SyncDocument) Clear() {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.doc.Store(&Document{
+		separator: sd.current().separator,
+	})
+}
+
+// Update executes the function as atomic transaction. It gets a copy
+// of the document to change. If the function returns nil the copy replaces
+// the document, otherwise the changes are discarded and the error is
+// returned. Updates are serialized, readers don't wait for them and see
+// either the state before or after the update. The function must neither
+// keep the document nor call methods of the synchronized document.
+func (sd *SyncDocument) Update(f func(doc *Document) error) error {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	current := sd.current()
+	doc := &Document{
+		separator: current.separator,
+		root:      copyNode(current.root),
+	}
+	if err := f(doc); err != nil {
+		return err
+	}
+	sd.doc.Store(doc)
+	return nil
+}
+
+// current returns the current document. It must not be changed.
+func (sd *SyncDocument) current() *Document {
+	doc, ok := sd.doc.Load().(*Document)
+	if !ok {
+		// Nothing stored so far.
+		return &Document{
+			separator: DefaultSeparator,
+		}
+	}
+	return doc
+}
+
+// EOF
@@ -17,14 +17,78 @@ import (
 	"strconv"
 )
 
+//--------------------
+// KIND
+//--------------------
+
+// Kind describes the JSON type of a value.
+type Kind int
+
+const (
+	// KindNull marks null or undefined values.
+	KindNull Kind = iota
+
+	// KindObject marks JSON objects.
+	KindObject
+
+	// KindArray marks JSON arrays.
+	KindArray
+
+	// KindString marks strings.
+	KindString
+
+	// KindNumber marks numbers.
+	KindNumber
+
+	// KindBool marks booleans.
+	KindBool
+)
+
+// String implements fmt.Stringer.
+func (k Kind) String() string {
+	switch k {
+	case KindNull:
+		return "null"
+	case KindObject:
+		return "object"
+	case KindArray:
+		return "array"
+	case KindString:
+		return "string"
+	case KindNumber:
+		return "number"
+	case KindBool:
+		return "bool"
+	}
+	return "unknown"
+}
+
+// kindOf returns the kind of the raw value.
+func kindOf(raw interface{}) Kind {
+	switch raw.(type) {
+	case map[string]interface{}:
+		return KindObject
+	case []interface{}:
+		return KindArray
+	case string:
+		return KindString
+	case int, float64:
+		return KindNumber
+	case bool:
+		return KindBool
+	}
+	return KindNull
+}
+
 //--------------------
 // VALUE
 //--------------------
 
 // Value contains one JSON value.
 type Value struct {
-	raw interface{}
-	err error
+	raw       interface{}
+	separator string
+	err       error
 }
 
 // IsUndefined returns true if this value is undefined.
@@ -32,6 +96,37 @@ func (v *Value) IsUndefined() bool {
 	return v.raw == nil
 }
 
+// Kind returns the JSON type of the value.
+func (v *Value) Kind() Kind {
+	return kindOf(v.raw)
+}
+
+// Len returns the number of elements of objects and arrays and
+// 1 for all other values including null, like Document.Length. In
+// case of an access error it returns -1.
+func (v *Value) Len() int {
+	if v.err != nil {
+		return -1
+	}
+	if o, ok := isObject(v.raw); ok {
+		return len(o)
+	}
+	if a, ok := isArray(v.raw); ok {
+		return len(a)
+	}
+	return 1
+}
+
+// ValueAt returns the value at the path inside of this value. It
+// uses the separator of the document the value is taken from.
+func (v *Value) ValueAt(path string) *Value {
+	if v.err != nil {
+		return &Value{nil, v.separator, v.err}
+	}
+	n, err := valueAt(v.raw, splitPath(path, v.separator))
+	return &Value{n, v.separator, err}
+}
+
 // AsString returns the value as string.
 func (v *Value) AsString(dv string) string {
 	if v.IsUndefined() {
@@ -122,16 +217,59 @@ func (v *Value) AsBool(dv bool) bool {
 	return dv
 }
 
+// AsStringSlice returns the elements of an array value as strings.
+// Objects and arrays inside of the array are returned as JSON. If the
+// value is no array the default value is returned.
+func (v *Value) AsStringSlice(dv []string) []string {
+	a, ok := isArray(v.raw)
+	if !ok {
+		return dv
+	}
+	ss := make([]string, len(a))
+	for i, raw := range a {
+		ss[i] = (&Value{raw, v.separator, nil}).String()
+	}
+	return ss
+}
+
+// AsMap returns the fields of an object value as map of values. If
+// the value is no object the default value is returned.
+func (v *Value) AsMap(dv map[string]*Value) map[string]*Value {
+	o, ok := isObject(v.raw)
+	if !ok {
+		return dv
+	}
+	m := make(map[string]*Value, len(o))
+	for field, raw := range o {
+		m[field] = &Value{raw, v.separator, nil}
+	}
+	return m
+}
+
+// IsError returns true if the access to this value failed.
+func (v *Value) IsError() bool {
+	return v.err != nil
+}
+
+// Error returns a potential error of the access to this value.
+func (v *Value) Error() error {
+	return v.err
+}
+
 // Equals compares a value with the passed one.
 func (v *Value) Equals(to *Value) bool {
 	return reflect.DeepEqual(v.raw, to.raw)
 }
 
-// String implements fmt.Stringer.
+// String implements fmt.Stringer. Objects and arrays are
+// returned as JSON.
 func (v *Value) String() string {
 	if v.IsUndefined() {
 		return "null"
 	}
+	if _, ok := isValue(v.raw); !ok {
+		return jsonOf(v.raw)
+	}
 	return fmt.Sprintf("%v", v.raw)
 }
 
@@ -24,13 +24,21 @@ import (
 
 // NodeBuilder creates a node structure.
 type NodeBuilder struct {
-	stack []*tagNode
-	done  bool
+	stack    []*tagNode
+	done     bool
+	position Position
 }
 
 // NewNodeBuilder return a new nnode builder.
 func NewNodeBuilder() *NodeBuilder {
-	return &NodeBuilder{[]*tagNode{}, false}
+	return &NodeBuilder{
+		stack: []*tagNode{},
+	}
+}
+
+// SetPosition implements the PositionBuilder interface.
+func (nb *NodeBuilder) SetPosition(pos Position) {
+	nb.position = pos
 }
 
 // Root returns the root node of the read document.
@@ -50,10 +58,19 @@ func (nb *NodeBuilder) BeginTagNode(tag string) error {
 	if err != nil {
 		return err
 	}
+	t.position = nb.position
 	nb.stack = append(nb.stack, t)
 	return nil
 }
 
+// BeginTagNodeWithAttributes implements the AttributeBuilder interface.
+func (nb *NodeBuilder) BeginTagNodeWithAttributes(tag string, attributes Attributes) error {
+	if err := nb.BeginTagNode(tag); err != nil {
+		return err
+	}
+	return nb.stack[len(nb.stack)-1].setAttributes(attributes)
+}
+
 // EndTagNode implements the Builder interface.
 func (nb *NodeBuilder) EndTagNode() error {
 	if nb.done {
@@ -77,7 +94,9 @@ func (nb *NodeBuilder) TextNode(text string) error {
 		return failure.New("building is already done")
 	}
 	if len(nb.stack) > 0 {
-		nb.stack[len(nb.stack)-1].appendTextNode(text)
+		if n := nb.stack[len(nb.stack)-1].appendTextNode(text); n != nil {
+			n.position = nb.position
+		}
 		return nil
 	}
 	return failure.New("no opening tag for text")
@@ -89,7 +108,9 @@ func (nb *NodeBuilder) RawNode(raw string) error {
 		return failure.New("building is already done")
 	}
 	if len(nb.stack) > 0 {
-		nb.stack[len(nb.stack)-1].appendRawNode(raw)
+		if n := nb.stack[len(nb.stack)-1].appendRawNode(raw); n != nil {
+			n.position = nb.position
+		}
 		return nil
 	}
 	return failure.New("no opening tag for raw text")
@@ -101,7 +122,9 @@ func (nb *NodeBuilder) CommentNode(comment string) error {
 		return failure.New("building is already done")
 	}
 	if len(nb.stack) > 0 {
-		nb.stack[len(nb.stack)-1].appendCommentNode(comment)
+		if n := nb.stack[len(nb.stack)-1].appendCommentNode(comment); n != nil {
+			n.position = nb.position
+		}
 		return nil
 	}
 	return failure.New("no opening tag for comment")
@@ -195,4 +218,32 @@ func (tb *KeyStringValueTreeBuilder) CommentNode(comment string) error {
 	return nil
 }
 
+//--------------------
+// HELPERS
+//--------------------
+
+// beginTagNode begins a tag node with the attributes. Builders not
+// implementing AttributeBuilder get the attributes as child tag nodes
+// containing the values as text.
+func beginTagNode(builder Builder, tag string, attributes Attributes) error {
+	if ab, ok := builder.(AttributeBuilder); ok && len(attributes) > 0 {
+		return ab.BeginTagNodeWithAttributes(tag, attributes)
+	}
+	if err := builder.BeginTagNode(tag); err != nil {
+		return err
+	}
+	for _, a := range attributes {
+		if err := builder.BeginTagNode(a.Name); err != nil {
+			return err
+		}
+		if err := builder.TextNode(a.Value); err != nil {
+			return err
+		}
+		if err := builder.EndTagNode(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // EOF
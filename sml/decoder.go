@@ -0,0 +1,306 @@
+// Tideland Go Text - Simple Markup Language
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package sml // import "tideland.dev/go/text/sml"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"io"
+	"strings"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// TOKEN
+//--------------------
+
+// TokenKind describes the kind of a token.
+type TokenKind int
+
+// Kinds of tokens.
+const (
+	OpenToken TokenKind = iota + 1
+	CloseToken
+	TextToken
+	RawToken
+	CommentToken
+)
+
+// String implements fmt.Stringer.
+func (k TokenKind) String() string {
+	switch k {
+	case OpenToken:
+		return "open"
+	case CloseToken:
+		return "close"
+	case TextToken:
+		return "text"
+	case RawToken:
+		return "raw"
+	case CommentToken:
+		return "comment"
+	}
+	return "invalid"
+}
+
+// Token is one event of a read SML document. Open and close tokens
+// contain the tag, open tokens also the attributes. Texts and comments
+// are trimmed like in node trees, raw data is unchanged.
+type Token struct {
+	Kind       TokenKind
+	Tag        []string
+	Attributes Attributes
+	Text       string
+	Position   Position
+}
+
+//--------------------
+// DECODER
+//--------------------
+
+// Decoder reads a SML document token by token. Only the current token
+// and the tags of the open tag nodes are kept, so also large documents
+// can be read with little memory.
+type Decoder struct {
+	mr       *mlReader
+	recorder *tokenRecorder
+	stack    [][]string
+	started  bool
+	closing  bool
+	done     bool
+	err      error
+}
+
+// NewDecoder creates a decoder reading from the reader. Like with
+// ReadSML brackets directly following a tag contain its attributes,
+// they are passed with the open token. Brackets not containing valid
+// attributes are read as text.
+func NewDecoder(reader io.Reader) *Decoder {
+	recorder := &tokenRecorder{}
+	return &Decoder{
+		mr:       newMLReader(reader, recorder, false),
+		recorder: recorder,
+	}
+}
+
+// NewDecoderWithAttributes creates a decoder reading from the reader.
+// Like with ReadSMLWithAttributes brackets directly following a tag
+// must contain valid attributes, otherwise an error is returned.
+func NewDecoderWithAttributes(reader io.Reader) *Decoder {
+	d := NewDecoder(reader)
+	d.mr.strict = true
+	return d
+}
+
+// Token returns the next token of the document. At the end of the
+// root tag node it returns io.EOF, content after it is not read. Errors
+// are returned as *ParseError, the decoder then returns the same error
+// for all following calls.
+func (d *Decoder) Token() (Token, error) {
+	if d.err != nil {
+		return Token{}, d.err
+	}
+	if d.done {
+		return Token{}, io.EOF
+	}
+	token, err := d.next()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = d.mr.parseError(err)
+		return Token{}, d.err
+	}
+	return token, nil
+}
+
+// Skip reads all tokens until the close token of the most recently
+// read open token. So after reading an open token its tag node with
+// all children can be skipped.
+func (d *Decoder) Skip() error {
+	depth := len(d.stack)
+	if depth == 0 {
+		return failure.New("no open tag to skip")
+	}
+	for len(d.stack) >= depth {
+		if _, err := d.Token(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Depth returns the number of open tag nodes.
+func (d *Decoder) Depth() int {
+	return len(d.stack)
+}
+
+// next reads the next token.
+func (d *Decoder) next() (Token, error) {
+	switch {
+	case d.closing:
+		d.closing = false
+		return d.closeTag(d.mr.runePos), nil
+	case !d.started:
+		d.started = true
+		if err := d.mr.readPreliminary(); err != nil {
+			return Token{}, err
+		}
+		return d.openTag(d.mr.runePos)
+	}
+	for {
+		_, rc, err := d.mr.readRune()
+		switch {
+		case err != nil:
+			return Token{}, err
+		case rc == rcEOF:
+			return Token{}, failure.New("unexpected end of file while reading children")
+		case rc == rcClose:
+			return d.closeTag(d.mr.runePos), nil
+		case rc == rcOpen:
+			return d.readBracedContent(d.mr.runePos)
+		default:
+			if err = d.mr.unreadRune(); err != nil {
+				return Token{}, err
+			}
+			if err = d.mr.readTextNode(); err != nil {
+				return Token{}, err
+			}
+			if d.recorder.token.Text != "" {
+				return d.recorder.token, nil
+			}
+		}
+	}
+}
+
+// readBracedContent reads the tag node, raw node, or comment
+// opened at the position.
+func (d *Decoder) readBracedContent(start Position) (Token, error) {
+	_, rc, err := d.mr.readRune()
+	switch {
+	case err != nil:
+		return Token{}, err
+	case rc == rcEOF:
+		return Token{}, failure.New("unexpected end of file while reading a tag or raw node")
+	case rc == rcTag:
+		if err = d.mr.unreadRune(); err != nil {
+			return Token{}, err
+		}
+		return d.openTag(start)
+	case rc == rcExclamation:
+		err = d.mr.readRawNode(start)
+	case rc == rcHash:
+		err = d.mr.readCommentNode(start)
+	default:
+		err = failure.New("invalid character after opening")
+	}
+	return d.recorder.token, err
+}
+
+// openTag reads the tag and attributes of a tag node opened at
+// the position.
+func (d *Decoder) openTag(start Position) (Token, error) {
+	tag, rc, err := d.mr.readTag()
+	if err != nil {
+		return Token{}, err
+	}
+	var attributes Attributes
+	if rc == rcSpace {
+		if attributes, err = d.mr.readTagAttributes(); err != nil {
+			return Token{}, err
+		}
+	}
+	tn, err := newTagNode(tag)
+	if err != nil {
+		return Token{}, err
+	}
+	if err = tn.setAttributes(attributes); err != nil {
+		return Token{}, err
+	}
+	d.stack = append(d.stack, tn.tag)
+	d.closing = rc == rcClose
+	return Token{
+		Kind:       OpenToken,
+		Tag:        tn.Tag(),
+		Attributes: tn.Attributes(),
+		Position:   start,
+	}, nil
+}
+
+// closeTag closes the current tag node at the position.
+func (d *Decoder) closeTag(pos Position) Token {
+	l := len(d.stack)
+	tag := d.stack[l-1]
+	d.stack = d.stack[:l-1]
+	d.done = l == 1
+	return Token{
+		Kind:     CloseToken,
+		Tag:      append([]string{}, tag...),
+		Position: pos,
+	}
+}
+
+//--------------------
+// TOKEN RECORDER
+//--------------------
+
+// tokenRecorder is the builder used by the reader of the decoder
+// to pass texts, raw data, and comments as token.
+type tokenRecorder struct {
+	position Position
+	token    Token
+}
+
+// SetPosition implements the PositionBuilder interface.
+func (tr *tokenRecorder) SetPosition(pos Position) {
+	tr.position = pos
+}
+
+// BeginTagNode implements the Builder interface. Tags are
+// read by the decoder itself.
+func (tr *tokenRecorder) BeginTagNode(tag string) error {
+	return failure.New("unexpected tag node")
+}
+
+// EndTagNode implements the Builder interface. Tags are
+// read by the decoder itself.
+func (tr *tokenRecorder) EndTagNode() error {
+	return failure.New("unexpected end of tag node")
+}
+
+// TextNode implements the Builder interface.
+func (tr *tokenRecorder) TextNode(text string) error {
+	tr.record(TextToken, strings.TrimSpace(text))
+	return nil
+}
+
+// RawNode implements the Builder interface.
+func (tr *tokenRecorder) RawNode(raw string) error {
+	tr.record(RawToken, raw)
+	return nil
+}
+
+// CommentNode implements the Builder interface.
+func (tr *tokenRecorder) CommentNode(comment string) error {
+	tr.record(CommentToken, strings.TrimSpace(comment))
+	return nil
+}
+
+// record sets the token.
+func (tr *tokenRecorder) record(kind TokenKind, text string) {
+	tr.token = Token{
+		Kind:     kind,
+		Text:     text,
+		Position: tr.position,
+	}
+}
+
+// EOF
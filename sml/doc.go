@@ -12,6 +12,48 @@
 // and '-'. Also several parts of the tag can be separated by colons.
 // The package contains a kind of DOM as well as a parser and a
 // processor. The latter is used e.g. for printing SML documents.
+//
+// Optional attributes can follow directly after the tag in brackets,
+// values containing spaces are quoted.
+//
+//	{a [href=/x title="Go"] link}
+//
+// Quotes inside of quoted values as well as a bracket at the beginning
+// of a text are escaped with '^'. Builders implementing AttributeBuilder
+// and processors implementing AttributeProcessor get the attributes,
+// other builders get them as child tag nodes like {a {href /x} link}.
+// ReadSML() reads brackets not containing valid attributes like in
+// {path [$HOME]} as text, while ReadSMLWithAttributes() returns an
+// error for them.
+//
+// Errors while reading are returned as ParseError containing the line,
+// column, and byte offset. Builders implementing PositionBuilder get the
+// positions of the nodes.
+//
+// The nodes of this package also implement NavigableNode, which provides
+// the attributes, positions, children, and parents. It is a separate
+// interface, so existing implementations of Node stay valid.
+//
+//	nn, ok := node.(sml.NavigableNode)
+//
+// Find() and FindAll() walk along a path of tags, Select() queries the
+// tree with selectors like
+//
+//	html > body > p:intro
+//
+// Nodes can be created with NewTagNode() and the other constructors. Trees
+// can be changed with functions like InsertChild(), MoveChild(), SetTag(),
+// or SetText() before writing them again.
+//
+// Beside the standard SML notation documents can be written as XML, HTML5,
+// or Markdown. The writers can also be registered as plugins for single
+// tags of a document.
+//
+// ReadXML() and ReadHTML() read XML and HTML documents with any builder, so
+// they can be converted into SML.
+//
+// Large documents can be read token by token with a Decoder instead of
+// building a tree. Its Skip() method skips the tag node read last.
 package sml // import "tideland.dev/go/text/sml"
 
 // EOF
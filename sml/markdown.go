@@ -0,0 +1,357 @@
+// Tideland Go Text - Simple Markup Language
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package sml // import "tideland.dev/go/text/sml"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"strings"
+)
+
+//--------------------
+// MARKDOWN WRITER PROCESSOR
+//--------------------
+
+// markdownTag is an open tag of the Markdown writer.
+type markdownTag struct {
+	tag  string
+	link string
+}
+
+// markdownList is an open list of the Markdown writer.
+type markdownList struct {
+	ordered bool
+	items   int
+	width   int
+}
+
+// markdownWriter writes a ML document in Markdown notation.
+type markdownWriter struct {
+	context    *WriterContext
+	tags       []markdownTag
+	lists      []markdownList
+	code       int
+	written    bool
+	newlines   int
+	fresh      bool
+	space      bool
+	blockEnded bool
+}
+
+// NewMarkdownWriter creates a new writer for a ML document in
+// Markdown notation. It maps the tags h1 to h6, p, em, strong, ul, ol,
+// li, a with the attributes href and title, and code. Raw data is
+// written as fenced code block, inside of code inline. Other tags are
+// ignored while their content is written. The writer does its own
+// layout, so pretty printing and indentation of the context don't apply.
+func NewMarkdownWriter() WriterProcessor {
+	return &markdownWriter{}
+}
+
+// SetContext sets the writer context.
+func (w *markdownWriter) SetContext(ctx *WriterContext) {
+	w.context = ctx
+}
+
+// OwnLayout implements LayoutWriterProcessor.
+func (w *markdownWriter) OwnLayout() bool {
+	return true
+}
+
+// OpenTag writes the opening of a tag.
+func (w *markdownWriter) OpenTag(tag []string) error {
+	return w.OpenTagWithAttributes(tag, nil)
+}
+
+// OpenTagWithAttributes writes the Markdown opening the tag.
+func (w *markdownWriter) OpenTagWithAttributes(tag []string, attributes Attributes) error {
+	mt := markdownTag{tag: tag[0]}
+	var err error
+	switch mt.tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		err = w.beginBlock(strings.Repeat("#", int(mt.tag[1]-'0')) + " ")
+	case "p":
+		err = w.beginBlock("")
+	case "ul", "ol":
+		err = w.breakLines(w.blockBreaks())
+		w.lists = append(w.lists, markdownList{ordered: mt.tag == "ol"})
+		w.blockEnded = false
+	case "li":
+		err = w.beginItem()
+	case "em":
+		err = w.inline("*", false)
+	case "strong":
+		err = w.inline("**", false)
+	case "code":
+		err = w.inline("`", false)
+		w.code++
+	case "a":
+		if href, ok := attributes.Get("href"); ok {
+			mt.link = href
+			if title, ok := attributes.Get("title"); ok {
+				mt.link = fmt.Sprintf("%s %q", href, title)
+			}
+			err = w.inline("[", false)
+		}
+	}
+	w.tags = append(w.tags, mt)
+	return err
+}
+
+// CloseTag writes the Markdown closing the tag.
+func (w *markdownWriter) CloseTag(tag []string) error {
+	mt := w.tags[len(w.tags)-1]
+	w.tags = w.tags[:len(w.tags)-1]
+	var err error
+	switch mt.tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6", "p", "li":
+		w.endBlock()
+	case "ul", "ol":
+		w.lists = w.lists[:len(w.lists)-1]
+		w.endBlock()
+	case "em":
+		err = w.write("*")
+	case "strong":
+		err = w.write("**")
+	case "code":
+		w.code--
+		err = w.write("`")
+	case "a":
+		if mt.link != "" {
+			err = w.write("](" + mt.link + ")")
+		}
+	}
+	if err == nil && len(w.tags) == 0 && w.written && w.newlines == 0 {
+		// Document done.
+		err = w.write("\n")
+	}
+	return err
+}
+
+// Text writes a text with an escaping of Markdown characters. At
+// the start of lines also markers of headings and lists are escaped.
+func (w *markdownWriter) Text(text string) error {
+	if w.code > 0 {
+		return w.inline(text, true)
+	}
+	var buf strings.Builder
+	lineStart := w.atLineStart() || w.blockEnded || w.fresh
+	number := false
+	for i, r := range text {
+		switch {
+		case strings.ContainsRune("\\`*_[]<>", r):
+			buf.WriteRune('\\')
+		case lineStart && strings.ContainsRune("#-+", r):
+			buf.WriteRune('\\')
+		case number && (r == '.' || r == ')') && endsMarker(text[i+1:]):
+			// Ordered list marker like "1." or "1)".
+			buf.WriteRune('\\')
+		}
+		buf.WriteRune(r)
+		switch {
+		case r == '\n':
+			lineStart = true
+			number = false
+		case lineStart && (r == ' ' || r == '\t'):
+			// Indentation keeps the line start.
+		case (lineStart || number) && r >= '0' && r <= '9':
+			lineStart = false
+			number = true
+		default:
+			lineStart = false
+			number = false
+		}
+	}
+	return w.inline(buf.String(), true)
+}
+
+// Raw writes raw data as fenced code block or inside of code inline.
+func (w *markdownWriter) Raw(raw string) error {
+	if w.code > 0 {
+		return w.inline(raw, true)
+	}
+	if err := w.beginBlock("```"); err != nil {
+		return err
+	}
+	raw = strings.TrimRight(strings.TrimLeft(raw, " \n"), " \t\n")
+	for _, line := range strings.Split(raw, "\n") {
+		if err := w.write("\n"); err != nil {
+			return err
+		}
+		if err := w.writeIndent(len(w.lists)); err != nil {
+			return err
+		}
+		if err := w.write(line); err != nil {
+			return err
+		}
+	}
+	if err := w.write("\n"); err != nil {
+		return err
+	}
+	if err := w.writeIndent(len(w.lists)); err != nil {
+		return err
+	}
+	if err := w.write("```"); err != nil {
+		return err
+	}
+	w.endBlock()
+	return nil
+}
+
+// Comment writes comment data as HTML comment.
+func (w *markdownWriter) Comment(comment string) error {
+	return w.inline("<!-- "+comment+" -->", true)
+}
+
+// beginBlock starts a new block with the marker.
+func (w *markdownWriter) beginBlock(marker string) error {
+	if err := w.breakLines(w.blockBreaks()); err != nil {
+		return err
+	}
+	if err := w.writeIndent(len(w.lists)); err != nil {
+		return err
+	}
+	if err := w.write(marker); err != nil {
+		return err
+	}
+	w.fresh = true
+	w.space = false
+	w.blockEnded = false
+	return nil
+}
+
+// beginItem starts a new item of the current list.
+func (w *markdownWriter) beginItem() error {
+	if len(w.lists) == 0 {
+		return w.beginBlock("")
+	}
+	if err := w.breakLines(1); err != nil {
+		return err
+	}
+	if err := w.writeIndent(len(w.lists) - 1); err != nil {
+		return err
+	}
+	list := &w.lists[len(w.lists)-1]
+	list.items++
+	marker := "- "
+	if list.ordered {
+		marker = fmt.Sprintf("%d. ", list.items)
+	}
+	list.width = len(marker)
+	if err := w.write(marker); err != nil {
+		return err
+	}
+	w.fresh = true
+	w.space = false
+	w.blockEnded = false
+	return nil
+}
+
+// endBlock marks the end of a block, so following content
+// starts a new one.
+func (w *markdownWriter) endBlock() {
+	w.space = false
+	w.blockEnded = true
+}
+
+// inline writes inline content. A space separates it from content
+// written before if wanted and it doesn't start with punctuation.
+func (w *markdownWriter) inline(s string, content bool) error {
+	if s == "" {
+		return nil
+	}
+	if w.blockEnded {
+		if err := w.beginBlock(""); err != nil {
+			return err
+		}
+	}
+	if w.space && !strings.ContainsAny(s[:1], ".,;:!?)") {
+		if err := w.write(" "); err != nil {
+			return err
+		}
+	}
+	if err := w.write(s); err != nil {
+		return err
+	}
+	w.space = content
+	return nil
+}
+
+// blockBreaks returns the number of newlines before a block,
+// inside of lists the blocks are tight.
+func (w *markdownWriter) blockBreaks() int {
+	if len(w.lists) > 0 {
+		return 1
+	}
+	return 2
+}
+
+// breakLines ends the current line with n newlines in total, unless
+// nothing is written so far or the block has just been started.
+func (w *markdownWriter) breakLines(n int) error {
+	if !w.written || w.fresh {
+		return nil
+	}
+	for w.newlines < n {
+		if err := w.write("\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeIndent writes the indentation for the content of
+// the given number of lists at the start of a line.
+func (w *markdownWriter) writeIndent(lists int) error {
+	if !w.atLineStart() {
+		return nil
+	}
+	width := 0
+	for _, list := range w.lists[:lists] {
+		width += list.width
+	}
+	if width == 0 {
+		return nil
+	}
+	return w.write(strings.Repeat(" ", width))
+}
+
+// endsMarker checks if the rest of a text following a possible
+// list marker ends the marker.
+func endsMarker(rest string) bool {
+	return rest == "" || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n'
+}
+
+// atLineStart checks if the next output starts a line.
+func (w *markdownWriter) atLineStart() bool {
+	return !w.written || w.newlines > 0
+}
+
+// write writes the string and keeps track of the newlines.
+func (w *markdownWriter) write(s string) error {
+	if s == "" {
+		return nil
+	}
+	if err := w.context.Writef("%s", s); err != nil {
+		return err
+	}
+	w.written = true
+	w.fresh = false
+	trimmed := strings.TrimRight(s, "\n")
+	if trimmed == "" {
+		w.newlines += len(s)
+	} else {
+		w.newlines = len(s) - len(trimmed)
+	}
+	return nil
+}
+
+// EOF
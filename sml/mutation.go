@@ -0,0 +1,285 @@
+// Tideland Go Text - Simple Markup Language
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package sml // import "tideland.dev/go/text/sml"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"strings"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// NODE CREATION
+//--------------------
+
+// NewTagNode creates a tag node without parent and children. The
+// tag and the attribute names are validated.
+func NewTagNode(tag string, attributes ...Attribute) (Node, error) {
+	tn, err := newTagNode(tag)
+	if err != nil {
+		return nil, err
+	}
+	if err := tn.setAttributes(attributes); err != nil {
+		return nil, err
+	}
+	return tn, nil
+}
+
+// NewTextNode creates a text node without parent.
+func NewTextNode(text string) Node {
+	return newTextNode(text)
+}
+
+// NewRawNode creates a raw node without parent.
+func NewRawNode(raw string) Node {
+	return newRawNode(raw)
+}
+
+// NewCommentNode creates a comment node without parent.
+func NewCommentNode(comment string) Node {
+	return newCommentNode(comment)
+}
+
+//--------------------
+// CHILD MUTATION
+//--------------------
+
+// AppendChild adds the child as last child of the parent tag node.
+// A child already having a parent is moved.
+func AppendChild(parent, child Node) error {
+	ptn, err := asTagNode(parent)
+	if err != nil {
+		return err
+	}
+	return InsertChild(parent, len(ptn.children), child)
+}
+
+// InsertChild inserts the child at the index of the children of the
+// parent tag node. An index equal to the number of children appends
+// it. A child already having a parent is moved.
+func InsertChild(parent Node, index int, child Node) error {
+	ptn, err := asTagNode(parent)
+	if err != nil {
+		return err
+	}
+	if err := checkInsertable(ptn, child); err != nil {
+		return err
+	}
+	if index < 0 || index > len(ptn.children) {
+		return failure.New("index %d out of range", index)
+	}
+	if parentOfNode(child) == ptn && indexOf(ptn, child) < index {
+		// Detaching shifts the following children.
+		index--
+	}
+	detach(child)
+	setParent(child, ptn)
+	ptn.children = append(ptn.children, nil)
+	copy(ptn.children[index+1:], ptn.children[index:])
+	ptn.children[index] = child
+	return nil
+}
+
+// RemoveChild removes the child at the index of the parent tag node
+// and returns it.
+func RemoveChild(parent Node, index int) (Node, error) {
+	ptn, err := asTagNode(parent)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIndex(ptn, index); err != nil {
+		return nil, err
+	}
+	child := ptn.children[index]
+	detach(child)
+	return child, nil
+}
+
+// ReplaceChild replaces the child at the index of the parent tag
+// node and returns the replaced one. A new child already having a
+// parent is moved.
+func ReplaceChild(parent Node, index int, child Node) (Node, error) {
+	ptn, err := asTagNode(parent)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIndex(ptn, index); err != nil {
+		return nil, err
+	}
+	replaced := ptn.children[index]
+	if replaced == child {
+		return replaced, nil
+	}
+	if err := InsertChild(parent, index, child); err != nil {
+		return nil, err
+	}
+	detach(replaced)
+	return replaced, nil
+}
+
+// MoveChild moves the child at index from to index to of the
+// parent tag node.
+func MoveChild(parent Node, from, to int) error {
+	ptn, err := asTagNode(parent)
+	if err != nil {
+		return err
+	}
+	if err := checkIndex(ptn, from); err != nil {
+		return err
+	}
+	if err := checkIndex(ptn, to); err != nil {
+		return err
+	}
+	child := ptn.children[from]
+	copy(ptn.children[from:], ptn.children[from+1:])
+	copy(ptn.children[to+1:], ptn.children[to:len(ptn.children)-1])
+	ptn.children[to] = child
+	return nil
+}
+
+// Detach removes the node from its parent. Nodes without
+// parent stay unchanged.
+func Detach(node Node) error {
+	if !isOwnNode(node) {
+		return failure.New("unsupported node type %T", node)
+	}
+	detach(node)
+	return nil
+}
+
+//--------------------
+// NODE MUTATION
+//--------------------
+
+// SetTag renames the tag node. The tag is validated.
+func SetTag(node Node, tag string) error {
+	tn, err := asTagNode(node)
+	if err != nil {
+		return err
+	}
+	vtag, err := ValidateTag(tag)
+	if err != nil {
+		return err
+	}
+	tn.tag = vtag
+	return nil
+}
+
+// SetAttributes replaces the attributes of the tag node. The
+// attribute names are validated.
+func SetAttributes(node Node, attributes Attributes) error {
+	tn, err := asTagNode(node)
+	if err != nil {
+		return err
+	}
+	return tn.setAttributes(attributes)
+}
+
+// SetText sets the content of a text, raw, or comment node. Like
+// when reading texts and comments are trimmed, raw data is not.
+func SetText(node Node, text string) error {
+	switch n := node.(type) {
+	case *textNode:
+		n.text = strings.TrimSpace(text)
+	case *rawNode:
+		n.raw = text
+	case *commentNode:
+		n.comment = strings.TrimSpace(text)
+	default:
+		return failure.New("node is no text, raw, or comment node")
+	}
+	return nil
+}
+
+//--------------------
+// MUTATION HELPERS
+//--------------------
+
+// asTagNode returns the node as tag node.
+func asTagNode(node Node) (*tagNode, error) {
+	tn, ok := node.(*tagNode)
+	if !ok {
+		return nil, failure.New("node is no tag node")
+	}
+	return tn, nil
+}
+
+// isOwnNode checks if the node has been created by this package.
+func isOwnNode(node Node) bool {
+	switch node.(type) {
+	case *tagNode, *textNode, *rawNode, *commentNode:
+		return true
+	}
+	return false
+}
+
+// checkInsertable checks if the child can be inserted into the
+// parent without creating a cycle.
+func checkInsertable(parent *tagNode, child Node) error {
+	if !isOwnNode(child) {
+		return failure.New("unsupported node type %T", child)
+	}
+	for ancestor := parent; ancestor != nil; ancestor = ancestor.parent {
+		if Node(ancestor) == child {
+			return failure.New("cannot insert node into itself")
+		}
+	}
+	return nil
+}
+
+// checkIndex checks if the index addresses a child of the parent.
+func checkIndex(parent *tagNode, index int) error {
+	if index < 0 || index >= len(parent.children) {
+		return failure.New("index %d out of range", index)
+	}
+	return nil
+}
+
+// parentOfNode returns the parent tag node of the node.
+func parentOfNode(node Node) *tagNode {
+	switch n := node.(type) {
+	case *tagNode:
+		return n.parent
+	case *textNode:
+		return n.parent
+	case *rawNode:
+		return n.parent
+	case *commentNode:
+		return n.parent
+	}
+	return nil
+}
+
+// indexOf returns the index of the child in the parent, -1 if
+// it's not found.
+func indexOf(parent *tagNode, child Node) int {
+	for i, c := range parent.children {
+		if c == child {
+			return i
+		}
+	}
+	return -1
+}
+
+// detach removes the node from its parent.
+func detach(node Node) {
+	parent := parentOfNode(node)
+	if parent == nil {
+		return
+	}
+	if i := indexOf(parent, node); i >= 0 {
+		parent.children = append(parent.children[:i], parent.children[i+1:]...)
+	}
+	setParent(node, nil)
+}
+
+// EOF
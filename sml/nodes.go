@@ -18,15 +18,42 @@ import (
 	"strings"
 )
 
+//--------------------
+// ATTRIBUTES
+//--------------------
+
+// Attribute is a named value of a tag node.
+type Attribute struct {
+	Name  string
+	Value string
+}
+
+// Attributes contains the attributes of a tag node in their order.
+type Attributes []Attribute
+
+// Get returns the value of the named attribute and true if
+// it exists.
+func (as Attributes) Get(name string) (string, bool) {
+	for _, a := range as {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
 //--------------------
 // TAG NODE
 //--------------------
 
-// tagNode represents a node with one multipart tag and zero to many
-// children nodes.
+// tagNode represents a node with one multipart tag, optional
+// attributes, and zero to many children nodes.
 type tagNode struct {
-	tag      []string
-	children []Node
+	parent     *tagNode
+	tag        []string
+	attributes Attributes
+	children   []Node
+	position   Position
 }
 
 // newTagNode creates a node with the given tag.
@@ -41,6 +68,16 @@ func newTagNode(tag string) (*tagNode, error) {
 	}, nil
 }
 
+// setAttributes validates the attributes and sets them.
+func (tn *tagNode) setAttributes(attributes Attributes) error {
+	vattributes, err := validateAttributes(attributes)
+	if err != nil {
+		return err
+	}
+	tn.attributes = vattributes
+	return nil
+}
+
 // appendTextNode creates a text node, appends it as last child
 // and returns it.
 func (tn *tagNode) appendTextNode(text string) *textNode {
@@ -71,6 +108,7 @@ func (tn *tagNode) appendCommentNode(comment string) *commentNode {
 
 // appendChild adds a node as last child.
 func (tn *tagNode) appendChild(n Node) {
+	setParent(n, tn)
 	tn.children = append(tn.children, n)
 }
 
@@ -81,6 +119,33 @@ func (tn *tagNode) Tag() []string {
 	return out
 }
 
+// Attributes returns the attributes.
+func (tn *tagNode) Attributes() Attributes {
+	if len(tn.attributes) == 0 {
+		return nil
+	}
+	out := make(Attributes, len(tn.attributes))
+	copy(out, tn.attributes)
+	return out
+}
+
+// Children returns the children.
+func (tn *tagNode) Children() []Node {
+	out := make([]Node, len(tn.children))
+	copy(out, tn.children)
+	return out
+}
+
+// Parent returns the parent tag node.
+func (tn *tagNode) Parent() Node {
+	return parentOf(tn.parent)
+}
+
+// Position returns the position in the read document.
+func (tn *tagNode) Position() Position {
+	return tn.position
+}
+
 // Len return the number of children of this node.
 func (tn *tagNode) Len() int {
 	return 1 + len(tn.children)
@@ -89,7 +154,7 @@ func (tn *tagNode) Len() int {
 // ProcessWith processes the node and all chidlren recursively
 // with the passed processor.
 func (tn *tagNode) ProcessWith(p Processor) error {
-	if err := p.OpenTag(tn.tag); err != nil {
+	if err := openTag(p, tn.tag, tn.Attributes()); err != nil {
 		return err
 	}
 	for _, child := range tn.children {
@@ -116,12 +181,14 @@ func (tn *tagNode) String() string {
 
 // textNode is a node containing some text.
 type textNode struct {
-	text string
+	parent   *tagNode
+	text     string
+	position Position
 }
 
 // newTextNode creates a new text node.
 func newTextNode(text string) *textNode {
-	return &textNode{strings.TrimSpace(text)}
+	return &textNode{text: strings.TrimSpace(text)}
 }
 
 // Tag returns nil.
@@ -129,6 +196,26 @@ func (tn *textNode) Tag() []string {
 	return nil
 }
 
+// Attributes returns nil.
+func (tn *textNode) Attributes() Attributes {
+	return nil
+}
+
+// Children returns nil.
+func (tn *textNode) Children() []Node {
+	return nil
+}
+
+// Parent returns the parent tag node.
+func (tn *textNode) Parent() Node {
+	return parentOf(tn.parent)
+}
+
+// Position returns the position in the read document.
+func (tn *textNode) Position() Position {
+	return tn.position
+}
+
 // Len returns the len of the text in the text node.
 func (tn *textNode) Len() int {
 	return len(tn.text)
@@ -151,12 +238,14 @@ func (tn *textNode) String() string {
 
 // rawNode is a node containing some raw data.
 type rawNode struct {
-	raw string
+	parent   *tagNode
+	raw      string
+	position Position
 }
 
 // newRawNode creates a new raw node.
 func newRawNode(raw string) *rawNode {
-	return &rawNode{raw}
+	return &rawNode{raw: raw}
 }
 
 // Tag returns nil.
@@ -164,6 +253,26 @@ func (rn *rawNode) Tag() []string {
 	return nil
 }
 
+// Attributes returns nil.
+func (rn *rawNode) Attributes() Attributes {
+	return nil
+}
+
+// Children returns nil.
+func (rn *rawNode) Children() []Node {
+	return nil
+}
+
+// Parent returns the parent tag node.
+func (rn *rawNode) Parent() Node {
+	return parentOf(rn.parent)
+}
+
+// Position returns the position in the read document.
+func (rn *rawNode) Position() Position {
+	return rn.position
+}
+
 // Len returns the len of the data in the raw node.
 func (rn *rawNode) Len() int {
 	return len(rn.raw)
@@ -186,12 +295,14 @@ func (rn *rawNode) String() string {
 
 // commentNode is a node containing a comment.
 type commentNode struct {
-	comment string
+	parent   *tagNode
+	comment  string
+	position Position
 }
 
 // newCommentNode creates a new comment node.
 func newCommentNode(comment string) *commentNode {
-	return &commentNode{strings.TrimSpace(comment)}
+	return &commentNode{comment: strings.TrimSpace(comment)}
 }
 
 // Tag returns nil.
@@ -199,6 +310,26 @@ func (cn *commentNode) Tag() []string {
 	return nil
 }
 
+// Attributes returns nil.
+func (cn *commentNode) Attributes() Attributes {
+	return nil
+}
+
+// Children returns nil.
+func (cn *commentNode) Children() []Node {
+	return nil
+}
+
+// Parent returns the parent tag node.
+func (cn *commentNode) Parent() Node {
+	return parentOf(cn.parent)
+}
+
+// Position returns the position in the read document.
+func (cn *commentNode) Position() Position {
+	return cn.position
+}
+
 // Len returns the len of the data in the comment node.
 func (cn *commentNode) Len() int {
 	return len(cn.comment)
@@ -219,17 +350,56 @@ func (cn *commentNode) String() string {
 // PRIVATE FUNCTIONS
 //--------------------
 
+// setParent sets the parent of the node.
+func setParent(n Node, parent *tagNode) {
+	switch tn := n.(type) {
+	case *tagNode:
+		tn.parent = parent
+	case *textNode:
+		tn.parent = parent
+	case *rawNode:
+		tn.parent = parent
+	case *commentNode:
+		tn.parent = parent
+	}
+}
+
+// parentOf returns the parent as node, nil if there's none.
+func parentOf(parent *tagNode) Node {
+	if parent == nil {
+		return nil
+	}
+	return parent
+}
+
+// openTag lets the processor open the tag, with attributes if
+// it is an AttributeProcessor.
+func openTag(p Processor, tag []string, attributes Attributes) error {
+	if ap, ok := p.(AttributeProcessor); ok {
+		return ap.OpenTagWithAttributes(tag, attributes)
+	}
+	return p.OpenTag(tag)
+}
+
 // validTagRe contains the regular expression for
 // the validation of tags.
 var validTagRe *regexp.Regexp
 
-// init the regexp for valid tags.
+// validAttributeNameRe contains the regular expression
+// for the validation of attribute names.
+var validAttributeNameRe *regexp.Regexp
+
+// init the regexps for valid tags and attribute names.
 func init() {
 	var err error
 	validTagRe, err = regexp.Compile(`^([a-z][a-z0-9]*(\-[a-z0-9]+)*)(:([a-z0-9]+(\-[a-z0-9]+)*))*$`)
 	if err != nil {
 		panic(err)
 	}
+	validAttributeNameRe, err = regexp.Compile(`^[a-z][a-z0-9]*([\-:][a-z0-9]+)*$`)
+	if err != nil {
+		panic(err)
+	}
 }
 
 // ValidateTag checks if a tag is valid. Only
@@ -245,4 +415,32 @@ func ValidateTag(tag string) ([]string, error) {
 	return ltags, nil
 }
 
+// ValidateAttributeName checks if an attribute name is valid.
+// Like with tags only the chars 'a' to 'z', '0' to '9', '-' and
+// ':' are accepted. It also transforms it to lowercase.
+func ValidateAttributeName(name string) (string, error) {
+	lname := strings.ToLower(name)
+	if !validAttributeNameRe.MatchString(lname) {
+		return "", fmt.Errorf("invalid attribute name: %q", name)
+	}
+	return lname, nil
+}
+
+// validateAttributes returns the attributes with validated names.
+// Duplicate names are not allowed.
+func validateAttributes(attributes Attributes) (Attributes, error) {
+	vattributes := make(Attributes, 0, len(attributes))
+	for _, a := range attributes {
+		name, err := ValidateAttributeName(a.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := vattributes.Get(name); ok {
+			return nil, fmt.Errorf("duplicate attribute: %q", name)
+		}
+		vattributes = append(vattributes, Attribute{name, a.Value})
+	}
+	return vattributes, nil
+}
+
 // EOF
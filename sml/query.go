@@ -0,0 +1,305 @@
+// Tideland Go Text - Simple Markup Language
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package sml // import "tideland.dev/go/text/sml"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"strings"
+	"unicode"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// FINDING
+//--------------------
+
+// Find returns the first tag node found by walking the tag path
+// through the children of the node, or nil if there's none. Each
+// element of the path matches tags like in selectors, e.g. "p:intro"
+// matches all tags starting with the parts "p" and "intro".
+func Find(node Node, tagPath ...string) Node {
+	found := FindAll(node, tagPath...)
+	if len(found) == 0 {
+		return nil
+	}
+	return found[0]
+}
+
+// FindAll returns all tag nodes found by walking the tag path
+// through the children of the node.
+func FindAll(node Node, tagPath ...string) []Node {
+	nodes := []Node{node}
+	for _, tag := range tagPath {
+		c := compound{tag: splitTag(tag)}
+		found := []Node{}
+		for _, n := range nodes {
+			for _, child := range navigableChildren(n) {
+				if c.matches(child) {
+					found = append(found, child)
+				}
+			}
+		}
+		nodes = found
+	}
+	if len(tagPath) == 0 {
+		return nil
+	}
+	return nodes
+}
+
+//--------------------
+// SELECTING
+//--------------------
+
+// Select returns all tag nodes of the tree starting at the node which
+// match the selector, in the order of the document. Selectors consist
+// of tags and combinators similar to CSS:
+//
+//	html > body > p:intro    p:intro being a child of body of html
+//	body em                  em being any descendant of body
+//	ul > *                   any child of ul
+//	a[href] a[title="Go"]    a with attribute href or title "Go"
+//
+// Tags match all tags starting with the given parts, a "*" matches
+// any tag or part.
+func Select(node Node, selector string) ([]Node, error) {
+	steps, err := parseSelector(selector)
+	if err != nil {
+		return nil, failure.Annotate(err, "invalid selector '%s'", selector)
+	}
+	selected := []Node{}
+	walk(node, func(n Node) {
+		if stepsMatch(n, steps) {
+			selected = append(selected, n)
+		}
+	})
+	return selected, nil
+}
+
+//--------------------
+// SELECTOR
+//--------------------
+
+// step is one compound of a selector together with its relation
+// to the previous one.
+type step struct {
+	child    bool
+	compound compound
+}
+
+// compound matches one tag node by tag and attributes.
+type compound struct {
+	tag        []string
+	attributes []attributeFilter
+}
+
+// attributeFilter checks the existence or the value of an attribute.
+type attributeFilter struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+// matches checks if the node is a tag node matching the compound.
+func (c compound) matches(n Node) bool {
+	tag := n.Tag()
+	if tag == nil || len(c.tag) > len(tag) {
+		return false
+	}
+	for i, part := range c.tag {
+		if part != "*" && part != tag[i] {
+			return false
+		}
+	}
+	attributes := navigableAttributes(n)
+	for _, af := range c.attributes {
+		value, ok := attributes.Get(af.name)
+		if !ok || (af.hasValue && value != af.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// stepsMatch checks if the node matches the last step and its
+// ancestors the steps before.
+func stepsMatch(n Node, steps []step) bool {
+	last := len(steps) - 1
+	if !steps[last].compound.matches(n) {
+		return false
+	}
+	if last == 0 {
+		return true
+	}
+	parent := navigableParent(n)
+	if steps[last].child {
+		return parent != nil && stepsMatch(parent, steps[:last])
+	}
+	for ; parent != nil; parent = navigableParent(parent) {
+		if stepsMatch(parent, steps[:last]) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSelector parses the selector into its steps.
+func parseSelector(selector string) ([]step, error) {
+	rs := []rune(strings.TrimSpace(selector))
+	steps := []step{}
+	child := false
+	for i := 0; i < len(rs); {
+		switch {
+		case unicode.IsSpace(rs[i]):
+			i++
+		case rs[i] == '>':
+			if len(steps) == 0 || child {
+				return nil, failure.New("misplaced '>' at index %d", i)
+			}
+			child = true
+			i++
+		default:
+			if len(steps) > 0 && i > 0 && !unicode.IsSpace(rs[i-1]) && rs[i-1] != '>' {
+				return nil, failure.New("invalid character at index %d", i)
+			}
+			c, next, err := parseCompound(rs, i)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step{child, c})
+			child = false
+			i = next
+		}
+	}
+	if len(steps) == 0 {
+		return nil, failure.New("empty selector")
+	}
+	if child {
+		return nil, failure.New("missing tag after '>'")
+	}
+	return steps, nil
+}
+
+// parseCompound parses one compound starting at the index and
+// returns it together with the index after it.
+func parseCompound(rs []rune, i int) (compound, int, error) {
+	c := compound{}
+	start := i
+	for i < len(rs) && isSelectorTagRune(rs[i]) {
+		i++
+	}
+	if i > start {
+		c.tag = splitTag(string(rs[start:i]))
+		for _, part := range c.tag {
+			if part == "" {
+				return compound{}, 0, failure.New("empty tag part at index %d", start)
+			}
+		}
+	}
+	for i < len(rs) && rs[i] == '[' {
+		af, next, err := parseAttributeFilter(rs, i+1)
+		if err != nil {
+			return compound{}, 0, err
+		}
+		c.attributes = append(c.attributes, af)
+		i = next
+	}
+	if i == start {
+		return compound{}, 0, failure.New("invalid character at index %d", i)
+	}
+	return c, i, nil
+}
+
+// parseAttributeFilter parses an attribute filter after the opening
+// bracket and returns the index after the closing bracket.
+func parseAttributeFilter(rs []rune, i int) (attributeFilter, int, error) {
+	af := attributeFilter{}
+	start := i
+	for i < len(rs) && rs[i] != '=' && rs[i] != ']' {
+		i++
+	}
+	name, err := ValidateAttributeName(string(rs[start:i]))
+	if err != nil {
+		return af, 0, err
+	}
+	af.name = name
+	if i < len(rs) && rs[i] == '=' {
+		i++
+		af.hasValue = true
+		quoted := i < len(rs) && rs[i] == '"'
+		if quoted {
+			i++
+		}
+		start = i
+		for i < len(rs) && ((quoted && rs[i] != '"') || (!quoted && rs[i] != ']')) {
+			i++
+		}
+		af.value = string(rs[start:i])
+		if quoted && i < len(rs) {
+			i++
+		}
+	}
+	if i >= len(rs) || rs[i] != ']' {
+		return af, 0, failure.New("unterminated attribute filter at index %d", start)
+	}
+	return af, i + 1, nil
+}
+
+// isSelectorTagRune checks if the rune is allowed in tags of selectors.
+func isSelectorTagRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') ||
+		r == '-' || r == ':' || r == '*'
+}
+
+// splitTag splits a tag of a path or selector into its parts. A
+// single "*" matches all tags.
+func splitTag(tag string) []string {
+	if tag == "*" {
+		return nil
+	}
+	return strings.Split(strings.ToLower(tag), ":")
+}
+
+// walk calls the function for the node and all its descendants
+// in document order.
+func walk(n Node, f func(n Node)) {
+	f(n)
+	for _, child := range navigableChildren(n) {
+		walk(child, f)
+	}
+}
+
+// navigableChildren returns the children of navigable nodes.
+func navigableChildren(n Node) []Node {
+	if nn, ok := n.(NavigableNode); ok {
+		return nn.Children()
+	}
+	return nil
+}
+
+// navigableAttributes returns the attributes of navigable nodes.
+func navigableAttributes(n Node) Attributes {
+	if nn, ok := n.(NavigableNode); ok {
+		return nn.Attributes()
+	}
+	return nil
+}
+
+// navigableParent returns the parent of navigable nodes.
+func navigableParent(n Node) Node {
+	if nn, ok := n.(NavigableNode); ok {
+		return nn.Parent()
+	}
+	return nil
+}
+
+// EOF
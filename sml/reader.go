@@ -14,12 +14,57 @@ package sml // import "tideland.dev/go/text/sml"
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"unicode"
+	"unicode/utf8"
 
 	"tideland.dev/go/trace/failure"
 )
 
+//--------------------
+// POSITION AND ERROR
+//--------------------
+
+// excerptLen is the maximum number of runes of an excerpt.
+const excerptLen = 32
+
+// Position describes a position in a SML document. Line and column
+// start with 1, the column is counted in runes. Offset is the byte
+// offset starting with 0.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// String implements fmt.Stringer.
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// ParseError is returned by ReadSML for errors while reading as well
+// as for errors returned by the builder. It contains the position and
+// the rune read last, an excerpt of the line up to it, and the original
+// error.
+type ParseError struct {
+	Position
+	Rune    rune
+	Excerpt string
+	Err     error
+}
+
+// Error implements the error interface.
+func (pe *ParseError) Error() string {
+	return fmt.Sprintf("%v (offset %d, rune %q, near %q): %v",
+		pe.Position, pe.Offset, pe.Rune, pe.Excerpt, pe.Err)
+}
+
+// Unwrap returns the original error.
+func (pe *ParseError) Unwrap() error {
+	return pe.Err
+}
+
 //--------------------
 // SML READER
 //--------------------
@@ -42,28 +87,80 @@ const (
 	chEscape      = '^'
 	chExclamation = '!'
 	chHash        = '#'
+
+	// Chars for attributes.
+	chAttributesOpen  = '['
+	chAttributesClose = ']'
+	chAssign          = '='
+	chQuote           = '"'
 )
 
 // ReadSML parses a SML document and uses the passed builder
-// for the callbacks.
+// for the callbacks. Brackets directly following a tag contain its
+// attributes. Builders implementing AttributeBuilder get them with
+// BeginTagNodeWithAttributes(), others as child tag nodes like with
+// ReadXML. Brackets not containing valid attributes are read as text.
+// Errors are returned as *ParseError containing the position in the
+// document.
 func ReadSML(reader io.Reader, builder Builder) error {
-	s := &mlReader{
-		reader:  bufio.NewReader(reader),
-		builder: builder,
-		index:   -1,
-	}
-	if err := s.readPreliminary(); err != nil {
-		return err
-	}
-	return s.readTagNode()
+	return newMLReader(reader, builder, false).read()
+}
+
+// ReadSMLWithAttributes parses a SML document like ReadSML, but
+// brackets directly following a tag must contain valid attributes,
+// otherwise an error is returned.
+func ReadSMLWithAttributes(reader io.Reader, builder AttributeBuilder) error {
+	return newMLReader(reader, builder, true).read()
 }
 
 // mlReader is used by ReadSML to parse a SML document
 // and return it as node structure.
 type mlReader struct {
-	reader  *bufio.Reader
-	builder Builder
-	index   int
+	reader      *bufio.Reader
+	builder     Builder
+	strict      bool
+	pos         Position
+	runePos     Position
+	lastRune    rune
+	line        []rune
+	prevLine    []rune
+	recording   bool
+	recorded    []rune
+	pending     []rune
+	lastPending bool
+}
+
+// mlReaderState is the state of the reader needed to read
+// recorded runes again.
+type mlReaderState struct {
+	pos      Position
+	runePos  Position
+	lastRune rune
+	line     []rune
+	prevLine []rune
+}
+
+// newMLReader creates a reader for the builder. If reading is strict
+// brackets following a tag must contain valid attributes.
+func newMLReader(reader io.Reader, builder Builder, strict bool) *mlReader {
+	return &mlReader{
+		reader:  bufio.NewReader(reader),
+		builder: builder,
+		strict:  strict,
+		pos:     Position{1, 1, 0},
+		runePos: Position{1, 1, 0},
+	}
+}
+
+// read reads the whole document.
+func (mr *mlReader) read() error {
+	if err := mr.readPreliminary(); err != nil {
+		return mr.parseError(err)
+	}
+	if err := mr.readTagNode(mr.runePos); err != nil {
+		return mr.parseError(err)
+	}
+	return nil
 }
 
 // readPreliminary reads the content before the first node.
@@ -81,13 +178,20 @@ func (mr *mlReader) readPreliminary() error {
 	}
 }
 
-// readNode reads the next tag node.
-func (mr *mlReader) readTagNode() error {
+// readNode reads the next tag node starting at the position.
+func (mr *mlReader) readTagNode(start Position) error {
 	tag, rc, err := mr.readTag()
 	if err != nil {
 		return err
 	}
-	if err = mr.builder.BeginTagNode(tag); err != nil {
+	var attributes Attributes
+	if rc == rcSpace {
+		if attributes, err = mr.readTagAttributes(); err != nil {
+			return err
+		}
+	}
+	mr.setPosition(start)
+	if err = beginTagNode(mr.builder, tag, attributes); err != nil {
 		return err
 	}
 	// Read children.
@@ -114,7 +218,135 @@ func (mr *mlReader) readTag() (string, int, error) {
 		case rc == rcSpace || rc == rcClose:
 			return buf.String(), rc, nil
 		default:
-			return "", 0, failure.New("invalid tag character at position %d", mr.index)
+			return "", 0, failure.New("invalid tag character")
+		}
+	}
+}
+
+// readTagAttributes reads the optional attributes following a tag.
+// If reading isn't strict invalid attributes are no error, instead
+// the read runes are read again as text.
+func (mr *mlReader) readTagAttributes() (Attributes, error) {
+	if mr.strict {
+		return mr.readAttributes()
+	}
+	state := mr.state()
+	mr.recording = true
+	attributes, err := mr.readAttributes()
+	if err == nil {
+		attributes, err = validateAttributes(attributes)
+	}
+	recorded := mr.recorded
+	mr.recording = false
+	mr.recorded = nil
+	if err != nil {
+		mr.rewind(state, recorded)
+		return nil, nil
+	}
+	return attributes, nil
+}
+
+// readAttributes reads the optional attributes in brackets
+// directly following the tag.
+func (mr *mlReader) readAttributes() (Attributes, error) {
+	r, _, err := mr.readRune()
+	if err != nil {
+		return nil, err
+	}
+	if r != chAttributesOpen {
+		return nil, mr.unreadRune()
+	}
+	attributes := Attributes{}
+	for {
+		r, rc, err := mr.readRune()
+		switch {
+		case err != nil:
+			return nil, err
+		case rc == rcEOF:
+			return nil, failure.New("unexpected end of file while reading attributes")
+		case rc == rcSpace:
+			continue
+		case r == chAttributesClose:
+			return attributes, nil
+		case rc == rcTag:
+			if err = mr.unreadRune(); err != nil {
+				return nil, err
+			}
+			attribute, err := mr.readAttribute()
+			if err != nil {
+				return nil, err
+			}
+			attributes = append(attributes, attribute)
+		default:
+			return nil, failure.New("invalid attribute character")
+		}
+	}
+}
+
+// readAttribute reads one attribute. Attributes without a value
+// get an empty one.
+func (mr *mlReader) readAttribute() (Attribute, error) {
+	var buf bytes.Buffer
+	for {
+		r, rc, err := mr.readRune()
+		switch {
+		case err != nil:
+			return Attribute{}, err
+		case rc == rcEOF:
+			return Attribute{}, failure.New("unexpected end of file while reading an attribute")
+		case rc == rcTag:
+			buf.WriteRune(r)
+		case r == chAssign:
+			value, err := mr.readAttributeValue()
+			return Attribute{buf.String(), value}, err
+		case rc == rcSpace || r == chAttributesClose:
+			return Attribute{Name: buf.String()}, mr.unreadRune()
+		default:
+			return Attribute{}, failure.New("invalid attribute character")
+		}
+	}
+}
+
+// readAttributeValue reads a quoted or unquoted attribute value.
+// Quoted values may contain escaped quotes.
+func (mr *mlReader) readAttributeValue() (string, error) {
+	var buf bytes.Buffer
+	r, _, err := mr.readRune()
+	if err != nil {
+		return "", err
+	}
+	quoted := r == chQuote
+	if !quoted {
+		if err = mr.unreadRune(); err != nil {
+			return "", err
+		}
+	}
+	for {
+		r, rc, err := mr.readRune()
+		switch {
+		case err != nil:
+			return "", err
+		case rc == rcEOF:
+			return "", failure.New("unexpected end of file while reading an attribute value")
+		case quoted && r == chQuote:
+			return buf.String(), nil
+		case quoted && rc == rcEscape:
+			r, _, err = mr.readRune()
+			if err != nil {
+				return "", err
+			}
+			if r != chQuote && r != chEscape {
+				return "", failure.New("invalid character after escaping")
+			}
+			buf.WriteRune(r)
+		case quoted:
+			buf.WriteRune(r)
+		case rc == rcSpace || r == chAttributesClose:
+			return buf.String(), mr.unreadRune()
+		case rc == rcOpen || rc == rcClose || r == chAttributesOpen || r == chQuote:
+			return "", failure.New("invalid attribute value character")
+		default:
+			buf.WriteRune(r)
 		}
 	}
 }
@@ -131,12 +363,11 @@ func (mr *mlReader) readTagChildren() error {
 		case rc == rcClose:
 			return nil
 		case rc == rcOpen:
-			if err = mr.readBracedContent(); err != nil {
+			if err = mr.readBracedContent(mr.runePos); err != nil {
 				return err
 			}
 		default:
-			mr.index--
-			if err = mr.reader.UnreadRune(); err != nil {
+			if err = mr.unreadRune(); err != nil {
 				return err
 			}
 			if err = mr.readTextNode(); err != nil {
@@ -146,9 +377,9 @@ func (mr *mlReader) readTagChildren() error {
 	}
 }
 
-// readBracedContent checks if the opening is for a tag node, raw node,
-// or comment and starts the reading of it.
-func (mr *mlReader) readBracedContent() error {
+// readBracedContent checks if the opening at the position is for a tag
+// node, raw node, or comment and starts the reading of it.
+func (mr *mlReader) readBracedContent(start Position) error {
 	_, rc, err := mr.readRune()
 	switch {
 	case err != nil:
@@ -156,21 +387,20 @@ func (mr *mlReader) readBracedContent() error {
 	case rc == rcEOF:
 		return failure.New("unexpected end of file while reading a tag or raw node")
 	case rc == rcTag:
-		mr.index--
-		if err = mr.reader.UnreadRune(); err != nil {
+		if err = mr.unreadRune(); err != nil {
 			return err
 		}
-		return mr.readTagNode()
+		return mr.readTagNode(start)
 	case rc == rcExclamation:
-		return mr.readRawNode()
+		return mr.readRawNode(start)
 	case rc == rcHash:
-		return mr.readCommentNode()
+		return mr.readCommentNode(start)
 	}
-	return failure.New("invalid character after opening at index %d", mr.index)
+	return failure.New("invalid character after opening")
 }
 
-// readRawNode reads a raw node.
-func (mr *mlReader) readRawNode() error {
+// readRawNode reads a raw node starting at the position.
+func (mr *mlReader) readRawNode(start Position) error {
 	var buf bytes.Buffer
 	for {
 		r, rc, err := mr.readRune()
@@ -187,6 +417,7 @@ func (mr *mlReader) readRawNode() error {
 			case rc == rcEOF:
 				return failure.New("unexpected end of file while reading a raw node")
 			case rc == rcClose:
+				mr.setPosition(start)
 				return mr.builder.RawNode(buf.String())
 			}
 			buf.WriteRune(chExclamation)
@@ -197,8 +428,8 @@ func (mr *mlReader) readRawNode() error {
 	}
 }
 
-// readCommentNode reads a raw node.
-func (mr *mlReader) readCommentNode() error {
+// readCommentNode reads a comment node starting at the position.
+func (mr *mlReader) readCommentNode(start Position) error {
 	var buf bytes.Buffer
 	for {
 		r, rc, err := mr.readRune()
@@ -215,6 +446,7 @@ func (mr *mlReader) readCommentNode() error {
 			case rc == rcEOF:
 				return failure.New("unexpected end of file while reading a comment node")
 			case rc == rcClose:
+				mr.setPosition(start)
 				return mr.builder.CommentNode(buf.String())
 			}
 			buf.WriteRune(chHash)
@@ -225,21 +457,28 @@ func (mr *mlReader) readCommentNode() error {
 	}
 }
 
-// readTextNode reads a text node.
+// readTextNode reads a text node. Its position is the one of
+// the first non-space rune.
 func (mr *mlReader) readTextNode() error {
 	var buf bytes.Buffer
+	start := mr.pos
+	started := false
 	for {
 		r, rc, err := mr.readRune()
+		if err == nil && !started && rc != rcSpace {
+			start = mr.runePos
+			started = true
+		}
 		switch {
 		case err != nil:
 			return err
 		case rc == rcEOF:
 			return failure.New("unexpected end of file while reading a text node")
 		case rc == rcOpen || rc == rcClose:
-			mr.index--
-			if err = mr.reader.UnreadRune(); err != nil {
+			if err = mr.unreadRune(); err != nil {
 				return err
 			}
+			mr.setPosition(start)
 			return mr.builder.TextNode(buf.String())
 		case rc == rcEscape:
 			r, rc, err = mr.readRune()
@@ -248,10 +487,10 @@ func (mr *mlReader) readTextNode() error {
 				return err
 			case rc == rcEOF:
 				return failure.New("unexpected end of file while reading a text node")
-			case rc == rcOpen || rc == rcClose || rc == rcEscape:
+			case rc == rcOpen || rc == rcClose || rc == rcEscape || r == chAttributesOpen:
 				buf.WriteRune(r)
 			default:
-				return failure.New("invalid character after escaping at index %d", mr.index)
+				return failure.New("invalid character after escaping")
 			}
 		default:
 			buf.WriteRune(r)
@@ -259,13 +498,35 @@ func (mr *mlReader) readTextNode() error {
 	}
 }
 
-// readRune reads one rune of the reader.
+// readRune reads one rune of the reader and tracks the position.
+// Pending runes to read again are read first.
 func (mr *mlReader) readRune() (r rune, rc int, err error) {
 	var size int
-	mr.index++
-	r, size, err = mr.reader.ReadRune()
-	if err != nil {
-		return 0, 0, err
+	if len(mr.pending) > 0 {
+		r, size = mr.pending[0], utf8.RuneLen(mr.pending[0])
+		mr.pending = mr.pending[1:]
+		mr.lastPending = true
+	} else {
+		r, size, err = mr.reader.ReadRune()
+		if err != nil {
+			return 0, 0, err
+		}
+		mr.lastPending = false
+	}
+	if mr.recording {
+		mr.recorded = append(mr.recorded, r)
+	}
+	mr.runePos = mr.pos
+	mr.lastRune = r
+	mr.pos.Offset += size
+	if r == '\n' {
+		mr.pos.Line++
+		mr.pos.Column = 1
+		mr.prevLine = mr.line
+		mr.line = nil
+	} else {
+		mr.pos.Column++
+		mr.appendLine(r)
 	}
 	switch {
 	case size == 0:
@@ -296,4 +557,81 @@ func (mr *mlReader) readRune() (r rune, rc int, err error) {
 	return
 }
 
+// appendLine appends the rune to the current line. Only the runes
+// needed for excerpts are kept, so long lines don't fill the memory.
+// One more than the excerpt length is needed for unreading.
+func (mr *mlReader) appendLine(r rune) {
+	mr.line = append(mr.line, r)
+	if len(mr.line) > 2*excerptLen {
+		mr.line = append([]rune{}, mr.line[len(mr.line)-excerptLen-1:]...)
+	}
+}
+
+// unreadRune unreads the last read rune and resets the position.
+func (mr *mlReader) unreadRune() error {
+	if mr.lastPending {
+		mr.pending = append([]rune{mr.lastRune}, mr.pending...)
+	} else if err := mr.reader.UnreadRune(); err != nil {
+		return err
+	}
+	if mr.recording && len(mr.recorded) > 0 {
+		mr.recorded = mr.recorded[:len(mr.recorded)-1]
+	}
+	mr.pos = mr.runePos
+	if mr.lastRune == '\n' {
+		mr.line = mr.prevLine
+	} else if len(mr.line) > 0 {
+		mr.line = mr.line[:len(mr.line)-1]
+	}
+	return nil
+}
+
+// state returns the current state of the reader.
+func (mr *mlReader) state() mlReaderState {
+	return mlReaderState{
+		pos:      mr.pos,
+		runePos:  mr.runePos,
+		lastRune: mr.lastRune,
+		line:     append([]rune{}, mr.line...),
+		prevLine: mr.prevLine,
+	}
+}
+
+// rewind resets the reader to the state and lets it read
+// the recorded runes again.
+func (mr *mlReader) rewind(state mlReaderState, recorded []rune) {
+	mr.pos = state.pos
+	mr.runePos = state.runePos
+	mr.lastRune = state.lastRune
+	mr.line = state.line
+	mr.prevLine = state.prevLine
+	mr.pending = append(recorded, mr.pending...)
+	mr.lastPending = false
+}
+
+// setPosition passes the position to builders interested in it.
+func (mr *mlReader) setPosition(pos Position) {
+	if pb, ok := mr.builder.(PositionBuilder); ok {
+		pb.SetPosition(pos)
+	}
+}
+
+// parseError wraps the error into a ParseError at the position
+// of the last read rune.
+func (mr *mlReader) parseError(err error) error {
+	line := mr.line
+	if len(line) == 0 {
+		line = mr.prevLine
+	}
+	if len(line) > excerptLen {
+		line = line[len(line)-excerptLen:]
+	}
+	return &ParseError{
+		Position: mr.runePos,
+		Rune:     mr.lastRune,
+		Excerpt:  string(line),
+		Err:      err,
+	}
+}
+
 // EOF
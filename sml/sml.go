@@ -31,6 +31,16 @@ type Processor interface {
 	Comment(comment string) error
 }
 
+// AttributeProcessor is a processor also interested in the
+// attributes of tags. If a processor implements it OpenTagWithAttributes()
+// is called instead of OpenTag().
+type AttributeProcessor interface {
+	Processor
+
+	// OpenTagWithAttributes is called if a tag is opened.
+	OpenTagWithAttributes(tag []string, attributes Attributes) error
+}
+
 //--------------------
 // BUILDER
 //--------------------
@@ -54,6 +64,27 @@ type Builder interface {
 	CommentNode(comment string) error
 }
 
+// AttributeBuilder is a builder also able to handle attributes of
+// tags. For tags with attributes the readers call
+// BeginTagNodeWithAttributes() instead of BeginTagNode().
+type AttributeBuilder interface {
+	Builder
+
+	// BeginTagNodeWithAttributes is called when a new tag node
+	// with attributes begins.
+	BeginTagNodeWithAttributes(tag string, attributes Attributes) error
+}
+
+// PositionBuilder is a builder interested in the positions of the
+// nodes in the document. The reader calls SetPosition() before each
+// callback creating a node.
+type PositionBuilder interface {
+	Builder
+
+	// SetPosition sets the position of the next node.
+	SetPosition(pos Position)
+}
+
 //--------------------
 // NODES
 //--------------------
@@ -74,4 +105,28 @@ type Node interface {
 	String() string
 }
 
+// NavigableNode is a node also providing its attributes, its position,
+// and the navigation in the tree. All nodes of this package implement
+// it, so a type assertion on a Node returns it. Other implementations
+// of Node are treated as nodes without attributes and children.
+type NavigableNode interface {
+	Node
+
+	// Attributes returns the attributes in case of a tag node,
+	// otherwise nil.
+	Attributes() Attributes
+
+	// Children returns the children in case of a tag node,
+	// otherwise nil.
+	Children() []Node
+
+	// Parent returns the parent tag node or nil in case of
+	// the root node.
+	Parent() Node
+
+	// Position returns the position of the node in the read
+	// document. It's zero for nodes not created by reading.
+	Position() Position
+}
+
 // EOF
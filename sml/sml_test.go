@@ -13,7 +13,9 @@ package sml_test
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"testing"
 
@@ -120,7 +122,7 @@ func TestNegativeNodeReading(t *testing.T) {
 	text := "{Foo {bar:1 Yadda {test} {} 1} {bar:2 Yadda 2}}"
 	builder := sml.NewNodeBuilder()
 	err := sml.ReadSML(strings.NewReader(text), builder)
-	assert.ErrorMatch(err, `.* invalid character after opening at index .*`)
+	assert.ErrorMatch(err, `.* invalid character after opening`)
 }
 
 // TestPositiveTreeReading checks the successful reading of trees.
@@ -180,10 +182,742 @@ for foo := 0; foo < 42; foo++ {
 	assert.Logf("===== DONE =====")
 }
 
+// TestAttributes checks reading and writing of tag attributes.
+func TestAttributes(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	in := `{p [class=intro] {a [href=/x title="Go ^"Tideland^" & more" Data-Rank=1 disabled] link} {b ^[no attribute]}}`
+	builder := sml.NewNodeBuilder()
+	err := sml.ReadSML(strings.NewReader(in), builder)
+	assert.Nil(err)
+	root, err := builder.Root()
+	assert.Nil(err)
+	assert.Equal(navigable(assert, root).Attributes(), sml.Attributes{{"class", "intro"}})
+
+	// Check via processor.
+	collector := &attributeCollector{}
+	assert.NoError(root.ProcessWith(collector))
+	assert.Equal(collector.attributes["a"], sml.Attributes{
+		{"href", "/x"},
+		{"title", `Go "Tideland" & more`},
+		{"data-rank", "1"},
+		{"disabled", ""},
+	})
+	assert.Length(collector.attributes["b"], 0)
+	title, ok := collector.attributes["a"].Get("title")
+	assert.True(ok)
+	assert.Equal(title, `Go "Tideland" & more`)
+	_, ok = collector.attributes["a"].Get("name")
+	assert.False(ok)
+
+	// Write SML and read it again.
+	buf := bytes.NewBufferString("")
+	ctx := sml.NewWriterContext(sml.NewStandardSMLWriter(), buf, false, "")
+	assert.NoError(sml.WriteSML(root, ctx))
+	out := ` {p [class="intro"] {a [href="/x" title="Go ^"Tideland^" & more" data-rank="1" disabled] link} {b ^[no attribute]}}`
+	assert.Equal(buf.String(), out)
+	builder = sml.NewNodeBuilder()
+	assert.NoError(sml.ReadSML(strings.NewReader(out), builder))
+	again, err := builder.Root()
+	assert.Nil(err)
+	assert.Equal(again.String(), root.String())
+	builder = sml.NewNodeBuilder()
+	assert.NoError(sml.ReadSMLWithAttributes(strings.NewReader(out), builder))
+	again, err = builder.Root()
+	assert.Nil(err)
+	assert.Equal(again.String(), root.String())
+
+	// Write XML.
+	buf = bytes.NewBufferString("")
+	ctx = sml.NewWriterContext(sml.NewXMLWriter("pre"), buf, false, "")
+	assert.NoError(sml.WriteSML(root, ctx))
+	assert.Equal(buf.String(), ` <p class="intro"> <a href="/x" title="Go &#34;Tideland&#34; &amp; more" data-rank="1" disabled="">`+
+		` link</a> <b> [no attribute]</b></p>`)
+
+	// Round trip of a written document.
+	doc := `{doc {a [href="/x" title="Go"] link}}`
+	root = readDocument(assert, doc)
+	buf.Reset()
+	ctx = sml.NewWriterContext(sml.NewStandardSMLWriter(), buf, false, "")
+	assert.NoError(sml.WriteSML(root, ctx))
+	assert.Equal(buf.String(), " "+doc)
+	builder = sml.NewNodeBuilder()
+	assert.NoError(sml.ReadSML(strings.NewReader(buf.String()), builder))
+	again, err = builder.Root()
+	assert.Nil(err)
+	assert.Equal(again.String(), root.String())
+	a := navigable(assert, navigable(assert, again).Children()[0])
+	assert.Equal(a.Attributes(), sml.Attributes{{"href", "/x"}, {"title", "Go"}})
+	assert.Length(a.Children(), 1)
+	assert.Equal(a.Children()[0].String(), "link")
+
+	// Errors.
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{`{a [href=/x}`, "invalid attribute value character"},
+		{`{a [href="/x]}`, "EOF"},
+		{`{a [href=/x href=/y]}`, "duplicate attribute"},
+		{`{a [-x]}`, "invalid attribute name"},
+		{`{a [=x]}`, "invalid attribute character"},
+		{`{a [x="^y"]}`, "invalid character after escaping"},
+	}
+	for _, test := range tests {
+		err := sml.ReadSMLWithAttributes(strings.NewReader(test.in), sml.NewNodeBuilder())
+		assert.ErrorContains(err, test.err, test.in)
+	}
+
+	// Brackets without valid attributes are text.
+	for _, test := range []struct {
+		in   string
+		text string
+	}{
+		{`{base-directory [$BASEDIR||/var/lib/myserver]}`, "[$BASEDIR||/var/lib/myserver]"},
+		{`{p [1] see footnote}`, "[1] see footnote"},
+		{`{p [a=1 a=2] twice}`, "[a=1 a=2] twice"},
+	} {
+		builder = sml.NewNodeBuilder()
+		err = sml.ReadSML(strings.NewReader(test.in), builder)
+		assert.Nil(err, test.in)
+		root, err = builder.Root()
+		assert.Nil(err)
+		nroot := navigable(assert, root)
+		assert.Length(nroot.Attributes(), 0)
+		assert.Equal(nroot.Children()[0].String(), test.text)
+	}
+	err = sml.ReadSMLWithAttributes(strings.NewReader(`{p [1] see footnote}`), sml.NewNodeBuilder())
+	assert.ErrorContains(err, "invalid attribute name")
+
+	// Other builders get attributes as tag nodes.
+	tb := sml.NewKeyStringValueTreeBuilder()
+	err = sml.ReadSML(strings.NewReader(`{config {foo [a=1 b="x y"]} {bar [$A||1]}}`), tb)
+	assert.Nil(err)
+	tree, err := tb.Tree()
+	assert.Nil(err)
+	value, err := tree.At("config", "foo", "a").Value()
+	assert.Nil(err)
+	assert.Equal(value, "1")
+	value, err = tree.At("config", "foo", "b").Value()
+	assert.Nil(err)
+	assert.Equal(value, "x y")
+	value, err = tree.At("config", "bar").Value()
+	assert.Nil(err)
+	assert.Equal(value, "[$A||1]")
+}
+
+// TestParseErrors checks the positions of errors while reading.
+func TestParseErrors(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var pe *sml.ParseError
+
+	err := sml.ReadSML(strings.NewReader("{a\n  {b ü {}}"), sml.NewNodeBuilder())
+	assert.True(errors.As(err, &pe))
+	assert.Equal(pe.Position, sml.Position{Line: 2, Column: 9, Offset: 12})
+	assert.Equal(pe.Rune, '}')
+	assert.Equal(pe.Excerpt, "  {b ü {}")
+	assert.ErrorMatch(pe.Err, `.* invalid character after opening`)
+	assert.ErrorMatch(err, `line 2, column 9 \(offset 12, rune '}', near "  {b ü {}"\): .*`)
+
+	// Long lines.
+	err = sml.ReadSML(strings.NewReader("{a "+strings.Repeat("x", 1000)+"{+}}"), sml.NewNodeBuilder())
+	assert.True(errors.As(err, &pe))
+	assert.Equal(pe.Position, sml.Position{Line: 1, Column: 1005, Offset: 1004})
+	assert.Equal(pe.Excerpt, strings.Repeat("x", 30)+"{+")
+	assert.ErrorMatch(err, `line 1, column 1005 \(offset 1004, rune '\+', near "x+\{\+"\): \[.*\] invalid character after opening`)
+
+	// Errors of builders.
+	err = sml.ReadSML(strings.NewReader("{foo {bar 1}{bar 2}}"), sml.NewKeyStringValueTreeBuilder())
+	assert.True(errors.As(err, &pe))
+	assert.Equal(pe.Line, 1)
+	assert.Equal(pe.Column, 19)
+	assert.ErrorMatch(errors.Unwrap(err), `.* node has multiple values`)
+
+	// Unexpected end.
+	err = sml.ReadSML(strings.NewReader("{foo\n{bar"), sml.NewNodeBuilder())
+	assert.True(errors.As(err, &pe))
+	assert.Equal(pe.Line, 2)
+	assert.True(errors.Is(err, io.EOF))
+}
+
+// TestNodePositions checks the positions of read nodes.
+func TestNodePositions(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	in := "{root\n  {a:x hello}\n  {# note #}\n  {! raw !}\n  world\n}"
+	builder := &positionRecorder{NodeBuilder: sml.NewNodeBuilder()}
+	err := sml.ReadSML(strings.NewReader(in), builder)
+	assert.Nil(err)
+	assert.Equal(builder.positions, []string{
+		"root 1:1:0",
+		"a:x 2:3:8",
+		"hello 2:8:13",
+		"note 3:3:22",
+		"raw 4:3:35",
+		"world 5:3:47",
+	})
+	root, err := builder.Root()
+	assert.Nil(err)
+	assert.Equal(navigable(assert, root).Position(), sml.Position{Line: 1, Column: 1, Offset: 0})
+
+	// Created nodes have no position.
+	root = createNodeStructure(assert)
+	assert.Equal(navigable(assert, root).Position(), sml.Position{})
+}
+
+// TestNavigation checks the navigation through a node tree.
+func TestNavigation(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	root := readQueryDocument(assert)
+
+	children := root.Children()
+	assert.Length(children, 2)
+	assert.Nil(root.Parent())
+	body := navigable(assert, children[1])
+	assert.Equal(body.Tag(), []string{"body"})
+	assert.Equal(body.Parent().Tag(), []string{"html"})
+	text := navigable(assert, mustFind(assert, body, "h1:title").Children()[0])
+	assert.Nil(text.Tag())
+	assert.Nil(text.Children())
+	assert.Equal(text.Parent().Tag(), []string{"h1", "title"})
+
+	// Find and FindAll.
+	assert.Equal(mustFind(assert, root, "body", "p:intro", "em").Children()[0].String(), "emphasized")
+	assert.Nil(sml.Find(root, "body", "p:other"))
+	assert.Nil(sml.Find(root, "head", "title", "em"))
+	assert.Length(sml.FindAll(root, "body", "ul", "li"), 3)
+	assert.Length(sml.FindAll(root, "body", "*"), 4)
+	assert.Length(sml.FindAll(root, "BODY", "P"), 2)
+	assert.Length(sml.FindAll(root), 0)
+
+	// Other node implementations have no children.
+	var node sml.Node = plainNode{}
+	_, ok := node.(sml.NavigableNode)
+	assert.False(ok)
+	assert.Length(sml.FindAll(node, "plain"), 0)
+	selected, err := sml.Select(node, "plain")
+	assert.Nil(err)
+	assert.Equal(len(selected), 1)
+}
+
+// TestSelect checks the selecting of nodes.
+func TestSelect(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	root := readQueryDocument(assert)
+	tests := []struct {
+		selector string
+		tags     []string
+		err      string
+	}{
+		{"html > body > p:intro", []string{"p:intro:preface"}, ""},
+		{"html p", []string{"p:intro:preface", "p:outro"}, ""},
+		{"html > p", []string{}, ""},
+		{"body em", []string{"em", "em"}, ""},
+		{"p:intro em", []string{"em"}, ""},
+		{"p:*:preface", []string{"p:intro:preface"}, ""},
+		{"ul>*", []string{"li:1", "li:2", "li:3"}, ""},
+		{"body > * > a", []string{"a", "a"}, ""},
+		{"a[href]", []string{"a", "a"}, ""},
+		{`a[title="The Go"]`, []string{"a"}, ""},
+		{"ul > [class=last]", []string{"li:3"}, ""},
+		{"[href=/y][title]", []string{}, ""},
+		{"title", []string{"title"}, ""},
+		{"", nil, "empty selector"},
+		{"> body", nil, "misplaced '>'"},
+		{"body >", nil, "missing tag after '>'"},
+		{"body > > p", nil, "misplaced '>'"},
+		{"p.intro", nil, "invalid character"},
+		{"a[href", nil, "unterminated attribute filter"},
+		{"a[-x]", nil, "invalid attribute name"},
+	}
+	for _, test := range tests {
+		nodes, err := sml.Select(root, test.selector)
+		if test.err != "" {
+			assert.ErrorContains(err, test.err, test.selector)
+			continue
+		}
+		assert.Nil(err, test.selector)
+		tags := []string{}
+		for _, node := range nodes {
+			tags = append(tags, strings.Join(node.Tag(), ":"))
+		}
+		assert.Equal(tags, test.tags, test.selector)
+	}
+}
+
+// TestHTMLWriter checks the writing of HTML5.
+func TestHTMLWriter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	in := `{html {body
+{p:intro:lead [data-x="a<b & ^"c^"" hidden] Text & more{br}{img [src=a.png alt=""]}}
+{! if a < b {} !}
+{# Comment #}}}`
+	root := readDocument(assert, in)
+
+	var buf bytes.Buffer
+	ctx := sml.NewWriterContext(sml.NewHTMLWriter(), &buf, true, "  ")
+	assert.NoError(sml.WriteSML(root, ctx))
+	assert.Equal(buf.String(), `<!DOCTYPE html>
+<html>
+  <body>
+    <p id="intro" class="lead" data-x="a&lt;b &amp; &#34;c&#34;" hidden>
+      Text &amp; more
+      <br>
+      <img src="a.png" alt>
+    </p>
+    <pre><code> if a &lt; b {} </code></pre>
+    <!-- Comment -->
+  </body>
+</html>
+`)
+
+	// Void elements must not have content.
+	root = readDocument(assert, `{p {br Text}}`)
+	buf.Reset()
+	ctx = sml.NewWriterContext(sml.NewHTMLWriter(), &buf, false, "")
+	assert.ErrorContains(sml.WriteSML(root, ctx), "void element 'br' cannot have content")
+}
+
+// TestMarkdownWriter checks the writing of Markdown.
+func TestMarkdownWriter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	in := `{doc
+{h1 A [test] document}
+{p A *simple* sentence with an {em emphasized}
+  and a {strong strong} text. See {a [href=/x title="Go"] the link}.}
+{ul
+  {li First}
+  {li Second {ol {li Sub {code a_b}} {li Sub}}}
+  {li Third {p More text.}}
+}
+{h3 Code}
+{! func main() {
+	println({code})
+} !}
+{# The end #}
+}`
+	root := readDocument(assert, in)
+	expected := `# A \[test\] document
+
+A \*simple\* sentence with an *emphasized* and a **strong** text. See [the link](/x "Go").
+
+- First
+- Second
+  1. Sub ` + "`a_b`" + `
+  2. Sub
+- Third
+  More text.
+
+### Code
+
+` + "```" + `
+func main() {
+	println({code})
+}
+` + "```" + `
+
+<!-- The end -->
+`
+	for _, pretty := range []bool{true, false} {
+		var buf bytes.Buffer
+		ctx := sml.NewWriterContext(sml.NewMarkdownWriter(), &buf, pretty, "  ")
+		assert.NoError(sml.WriteSML(root, ctx))
+		assert.Equal(buf.String(), expected)
+	}
+
+	// Markers at the start of lines.
+	root = readDocument(assert, `{doc
+{p 1. not a list}
+{p 2) neither {em 3.} 4.}
+{p 10.5 percent}
+{p - no + list # here}
+{ul {li - item} {li {em a} - b}}
+{p first line
+  - second line
+  3. third}
+}`)
+	var buf bytes.Buffer
+	ctx := sml.NewWriterContext(sml.NewMarkdownWriter(), &buf, false, "")
+	assert.NoError(sml.WriteSML(root, ctx))
+	assert.Equal(buf.String(), `1\. not a list
+
+2\) neither *3.* 4.
+
+10.5 percent
+
+\- no + list # here
+
+- \- item
+- *a* - b
+
+first line
+  \- second line
+  3\. third
+`)
+
+	// Markdown as plugin.
+	root = readDocument(assert, `{doc {title Test} {md {p Some {em text}.}}}`)
+	buf.Reset()
+	ctx = sml.NewWriterContext(sml.NewXMLWriter("pre"), &buf, false, "")
+	assert.NoError(ctx.Register("md", sml.NewMarkdownWriter()))
+	assert.NoError(sml.WriteSML(root, ctx))
+	assert.Equal(buf.String(), " <doc> <title> Test</title>Some *text*.\n</doc>")
+}
+
+// TestXMLReading checks the reading of XML and HTML documents.
+func TestXMLReading(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	in := `<?xml version="1.0" encoding="UTF-8"?>
+<!-- Ignored comment -->
+<Document xmlns="urn:test" xmlns:x="urn:x">
+  <Head_Line id="intro" class="lead">A &amp; B</Head_Line>
+  <p id="Not valid" x:Lang="en" data.x="a &lt; b">Some <em>text</em>.</p>
+  <p class="a b">Other</p>
+  <code><![CDATA[if a < b { }]]></code>
+  <!-- A comment -->
+  <empty/>
+</Document>`
+	builder := sml.NewNodeBuilder()
+	assert.NoError(sml.ReadXML(strings.NewReader(in), builder))
+	root, err := builder.Root()
+	assert.Nil(err)
+	var buf bytes.Buffer
+	ctx := sml.NewWriterContext(sml.NewStandardSMLWriter(), &buf, true, "  ")
+	assert.NoError(sml.WriteSML(root, ctx))
+	assert.Equal(buf.String(), `{document
+  {head-line:intro:lead
+    A & B
+  }
+  {p [id="Not valid" lang="en" data-x="a < b"]
+    Some
+    {em
+      text
+    }
+    .
+  }
+  {p [class="a b"]
+    Other
+  }
+  {code
+    {! if a < b { } !}
+  }
+  {# A comment #}
+  {empty
+  }
+}
+`)
+	p := mustFind(assert, root, "p")
+	assert.Equal(p.Position(), sml.Position{Line: 5, Column: 3, Offset: 169})
+
+	// Values changed by tag validation stay attributes.
+	builder = sml.NewNodeBuilder()
+	in = `<body><div id="Main" class="wide">A</div><div id="main" class="Wide">B</div></body>`
+	assert.NoError(sml.ReadXML(strings.NewReader(in), builder))
+	root, err = builder.Root()
+	assert.Nil(err)
+	divs := sml.FindAll(root, "div")
+	assert.Length(divs, 2)
+	assert.Equal(divs[0].Tag(), []string{"div"})
+	assert.Equal(navigable(assert, divs[0]).Attributes(), sml.Attributes{{Name: "id", Value: "Main"}, {Name: "class", Value: "wide"}})
+	assert.Equal(divs[1].Tag(), []string{"div", "main"})
+	assert.Equal(navigable(assert, divs[1]).Attributes(), sml.Attributes{{Name: "class", Value: "Wide"}})
+
+	// Attributes as tag nodes.
+	tb := sml.NewKeyStringValueTreeBuilder()
+	in = `<config><server host="localhost" port="80"><path>/api</path></server></config>`
+	assert.NoError(sml.ReadXML(strings.NewReader(in), tb))
+	tree, err := tb.Tree()
+	assert.Nil(err)
+	value, err := tree.At("config", "server", "port").Value()
+	assert.Nil(err)
+	assert.Equal(value, "80")
+	value, err = tree.At("config", "server", "path").Value()
+	assert.Nil(err)
+	assert.Equal(value, "/api")
+
+	// HTML.
+	in = `<html><body><p>One&nbsp;line<br>Next line</p><img src="a.png"></body></html>`
+	builder = sml.NewNodeBuilder()
+	assert.NoError(sml.ReadHTML(strings.NewReader(in), builder))
+	root, err = builder.Root()
+	assert.Nil(err)
+	assert.Length(sml.FindAll(root, "body", "p", "br"), 1)
+	assert.Equal(mustFind(assert, root, "body", "img").Attributes(), sml.Attributes{{Name: "src", Value: "a.png"}})
+
+	// Errors.
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{``, `line 1, column 1 \(offset 0, rune '\\x00', near ""\): .*missing root element`},
+		{`<a></a><b></b>`, `line 1, column 8 .*: .*multiple root elements`},
+		{`<a></a>text`, `line 1, column 8 .*: .*text outside of root element`},
+		{"<a>\n  <b></c></a>", `line 2, column 10 \(offset 13, rune '<', near "  <b></c>"\): .*element <b> closed by </c>`},
+		{`<a><b_></b_></a>`, `line 1, column 4 .*: invalid tag: "b-"`},
+	}
+	for _, test := range tests {
+		err := sml.ReadXML(strings.NewReader(test.in), sml.NewNodeBuilder())
+		var pe *sml.ParseError
+		assert.True(errors.As(err, &pe), test.in)
+		assert.ErrorMatch(err, test.err, test.in)
+	}
+}
+
+// TestDecoder checks the reading of documents token by token.
+func TestDecoder(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	in := `Preliminary {doc
+  {head {title Skipped {em text}}}
+  {p:intro [class=x] A ^{simple^} {em text}.}
+  {!  raw  !}
+  {# comment #}
+  {empty}
+}trailing`
+	d := sml.NewDecoderWithAttributes(strings.NewReader(in))
+	tokens := []string{}
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(err)
+		switch token.Kind {
+		case sml.OpenToken:
+			tokens = append(tokens, "open "+strings.Join(token.Tag, ":")+fmt.Sprint(token.Attributes))
+			if token.Tag[0] == "head" {
+				assert.NoError(d.Skip())
+				assert.Equal(d.Depth(), 1)
+			}
+		case sml.CloseToken:
+			tokens = append(tokens, "close "+strings.Join(token.Tag, ":"))
+		default:
+			tokens = append(tokens, fmt.Sprintf("%v %q", token.Kind, token.Text))
+		}
+	}
+	assert.Equal(tokens, []string{
+		"open doc[]",
+		"open head[]",
+		"open p:intro[{class x}]",
+		`text "A {simple}"`,
+		"open em[]",
+		`text "text"`,
+		"close em",
+		`text "."`,
+		"close p:intro",
+		`raw "  raw  "`,
+		`comment "comment"`,
+		"open empty[]",
+		"close empty",
+		"close doc",
+	})
+	_, err := d.Token()
+	assert.Equal(err, io.EOF)
+	assert.ErrorContains(d.Skip(), "no open tag to skip")
+
+	// Positions are the same as for node trees.
+	root := readDocument(assert, in)
+	d = sml.NewDecoderWithAttributes(strings.NewReader(in))
+	positions := []sml.Position{}
+	for token, err := d.Token(); err == nil; token, err = d.Token() {
+		if token.Kind != sml.CloseToken {
+			positions = append(positions, token.Position)
+		}
+	}
+	treePositions := []sml.Position{}
+	var collect func(n sml.Node)
+	collect = func(n sml.Node) {
+		nn := navigable(assert, n)
+		treePositions = append(treePositions, nn.Position())
+		for _, child := range nn.Children() {
+			collect(child)
+		}
+	}
+	collect(root)
+	assert.Equal(positions, treePositions)
+
+	// Large documents.
+	entries := 10000
+	r := io.MultiReader(
+		strings.NewReader("{log\n"),
+		strings.NewReader(strings.Repeat("{entry [level=info] Something happened.}\n", entries)),
+		strings.NewReader("}"),
+	)
+	d = sml.NewDecoder(r)
+	count := 0
+	for token, err := d.Token(); err != io.EOF; token, err = d.Token() {
+		assert.Nil(err)
+		if token.Kind == sml.TextToken {
+			count++
+		}
+	}
+	assert.Equal(count, entries)
+
+	// Brackets without valid attributes are text.
+	d = sml.NewDecoder(strings.NewReader(`{p [1] see footnote}`))
+	token, err := d.Token()
+	assert.Nil(err)
+	assert.Length(token.Attributes, 0)
+	token, err = d.Token()
+	assert.Nil(err)
+	assert.Equal(token.Text, "[1] see footnote")
+
+	// Errors.
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{"{doc {p text}", `line 1, column 13 .*: unexpected EOF`},
+		{"{doc {p text}}}", ""},
+		{"{doc\n{1st}}", `line 2, column 5 .*: invalid tag: "1st"`},
+		{"{doc {p [a b a] x}}", `line 1, column 15 .*: duplicate attribute: "a"`},
+		{"{doc {+}}", `line 1, column 7 .*: .*invalid character after opening`},
+	}
+	for _, test := range tests {
+		d = sml.NewDecoderWithAttributes(strings.NewReader(test.in))
+		var err error
+		for err == nil {
+			_, err = d.Token()
+		}
+		if test.err == "" {
+			assert.Equal(err, io.EOF, test.in)
+			continue
+		}
+		var pe *sml.ParseError
+		assert.True(errors.As(err, &pe), test.in)
+		assert.ErrorMatch(err, test.err, test.in)
+		_, again := d.Token()
+		assert.Equal(again, err, test.in)
+	}
+}
+
+// TestMutation checks the changing of node trees.
+func TestMutation(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	root := readQueryDocument(assert)
+	body := mustFind(assert, root, "body")
+	ul := mustFind(assert, body, "ul")
+	tags := func(node sml.NavigableNode) []string {
+		out := []string{}
+		for _, child := range node.Children() {
+			out = append(out, strings.Join(child.Tag(), ":"))
+		}
+		return out
+	}
+
+	// Insert, move, remove, and replace children.
+	li, err := sml.NewTagNode("li:0", sml.Attribute{Name: "Class", Value: "first"})
+	assert.Nil(err)
+	assert.NoError(sml.AppendChild(li, sml.NewTextNode("  Zeroth  ")))
+	assert.NoError(sml.InsertChild(ul, 0, li))
+	assert.Equal(tags(ul), []string{"li:0", "li:1", "li:2", "li:3"})
+	assert.Equal(navigable(assert, li).Parent(), ul)
+	assert.NoError(sml.MoveChild(ul, 3, 1))
+	assert.Equal(tags(ul), []string{"li:0", "li:3", "li:1", "li:2"})
+	assert.NoError(sml.MoveChild(ul, 0, 3))
+	assert.Equal(tags(ul), []string{"li:3", "li:1", "li:2", "li:0"})
+	removed, err := sml.RemoveChild(ul, 1)
+	assert.Nil(err)
+	assert.Equal(removed.Tag(), []string{"li", "1"})
+	assert.Nil(navigable(assert, removed).Parent())
+	assert.Equal(tags(ul), []string{"li:3", "li:2", "li:0"})
+	replaced, err := sml.ReplaceChild(ul, 0, removed)
+	assert.Nil(err)
+	assert.Equal(replaced.Tag(), []string{"li", "3"})
+	assert.Nil(navigable(assert, replaced).Parent())
+	assert.Equal(tags(ul), []string{"li:1", "li:2", "li:0"})
+
+	// Moving nodes between parents.
+	assert.NoError(sml.AppendChild(body, li))
+	assert.Equal(tags(ul), []string{"li:1", "li:2"})
+	assert.Equal(navigable(assert, li).Parent(), body)
+	assert.NoError(sml.InsertChild(ul, 2, li))
+	assert.Equal(tags(ul), []string{"li:1", "li:2", "li:0"})
+	assert.NoError(sml.InsertChild(ul, 1, ul.Children()[0]))
+	assert.Equal(tags(ul), []string{"li:1", "li:2", "li:0"})
+	assert.NoError(sml.Detach(li))
+	assert.Equal(tags(ul), []string{"li:1", "li:2"})
+	assert.NoError(sml.Detach(li))
+
+	// Rename tags and edit texts and attributes.
+	assert.NoError(sml.SetTag(ul, "OL:Numbered"))
+	assert.NoError(sml.SetAttributes(ul, sml.Attributes{{Name: "start", Value: "3"}}))
+	text := mustFind(assert, root, "body", "h1").Children()[0]
+	assert.NoError(sml.SetText(text, " A changed document "))
+	assert.NoError(sml.AppendChild(ul, sml.NewRawNode(" raw ")))
+	assert.NoError(sml.AppendChild(ul, sml.NewCommentNode(" list ")))
+	assert.Equal(text.String(), "A changed document")
+	selected, err := sml.Select(root, "body > ol:numbered[start=3]")
+	assert.Nil(err)
+	assert.Length(selected, 1)
+
+	// Write the changed tree.
+	var buf bytes.Buffer
+	ctx := sml.NewWriterContext(sml.NewStandardSMLWriter(), &buf, false, "")
+	assert.NoError(sml.WriteSML(ul, ctx))
+	assert.Equal(buf.String(), ` {ol:numbered [start="3"] {li:1 First} {li:2 Second} {!  raw  !} {# list #}}`)
+
+	// Errors.
+	_, err = sml.NewTagNode("li:0", sml.Attribute{Name: "a"}, sml.Attribute{Name: "A"})
+	assert.ErrorMatch(err, `duplicate attribute: "a"`)
+	assert.ErrorMatch(sml.SetTag(ul, "-"), `invalid tag: "-"`)
+	assert.ErrorContains(sml.AppendChild(text, li), "node is no tag node")
+	assert.ErrorContains(sml.AppendChild(ul, body), "cannot insert node into itself")
+	assert.ErrorContains(sml.AppendChild(ul, ul), "cannot insert node into itself")
+	assert.ErrorContains(sml.InsertChild(ul, 5, li), "index 5 out of range")
+	assert.ErrorContains(sml.MoveChild(ul, 0, -1), "index -1 out of range")
+	assert.ErrorContains(sml.SetText(ul, "text"), "node is no text, raw, or comment node")
+	_, err = sml.RemoveChild(ul, 9)
+	assert.ErrorContains(err, "index 9 out of range")
+}
+
 //--------------------
 // HELPERS
 //--------------------
 
+// readQueryDocument reads a document for navigation and queries.
+func readQueryDocument(assert *asserts.Asserts) sml.NavigableNode {
+	in := `{html
+{head {title A test document}}
+{body
+  {h1:title A test document}
+  {p:intro:preface A simple sentence with an {em emphasized} text
+    and a {a [href=/x title="The Go"] link}.}
+  {ul
+    {li:1 First}
+    {li:2 Second}
+    {li:3 [class=last] Third}
+  }
+  {p:outro An {em emphasized} and {a [href=/y] linked} text.}
+  {# Comment #}
+}}`
+	builder := sml.NewNodeBuilder()
+	assert.NoError(sml.ReadSMLWithAttributes(strings.NewReader(in), builder))
+	root, err := builder.Root()
+	assert.Nil(err)
+	return navigable(assert, root)
+}
+
+// readDocument reads a document into a node tree.
+func readDocument(assert *asserts.Asserts, in string) sml.NavigableNode {
+	builder := sml.NewNodeBuilder()
+	assert.NoError(sml.ReadSMLWithAttributes(strings.NewReader(in), builder))
+	root, err := builder.Root()
+	assert.Nil(err)
+	return navigable(assert, root)
+}
+
+// mustFind finds a node and asserts its existence.
+func mustFind(assert *asserts.Asserts, node sml.Node, tagPath ...string) sml.NavigableNode {
+	found := sml.Find(node, tagPath...)
+	assert.NotNil(found)
+	return navigable(assert, found)
+}
+
+// navigable asserts that the node is navigable and returns it.
+func navigable(assert *asserts.Asserts, node sml.Node) sml.NavigableNode {
+	nn, ok := node.(sml.NavigableNode)
+	assert.True(ok)
+	return nn
+}
+
 // Create a node structure.
 func createNodeStructure(assert *asserts.Asserts) sml.Node {
 	check := assert.NoError
@@ -261,4 +995,122 @@ func (w *liWriter) Comment(comment string) error {
 	return w.context.Writef("\n%s\n", comment)
 }
 
+// positionRecorder records the positions of the nodes.
+type positionRecorder struct {
+	*sml.NodeBuilder
+	position  sml.Position
+	positions []string
+}
+
+// SetPosition implements sml.PositionBuilder.
+func (r *positionRecorder) SetPosition(pos sml.Position) {
+	r.position = pos
+	r.NodeBuilder.SetPosition(pos)
+}
+
+// BeginTagNode implements sml.Builder.
+func (r *positionRecorder) BeginTagNode(tag string) error {
+	r.record(tag)
+	return r.NodeBuilder.BeginTagNode(tag)
+}
+
+// BeginTagNodeWithAttributes implements sml.AttributeBuilder.
+func (r *positionRecorder) BeginTagNodeWithAttributes(tag string, attributes sml.Attributes) error {
+	r.record(tag)
+	return r.NodeBuilder.BeginTagNodeWithAttributes(tag, attributes)
+}
+
+// TextNode implements sml.Builder.
+func (r *positionRecorder) TextNode(text string) error {
+	r.record(text)
+	return r.NodeBuilder.TextNode(text)
+}
+
+// RawNode implements sml.Builder.
+func (r *positionRecorder) RawNode(raw string) error {
+	r.record(raw)
+	return r.NodeBuilder.RawNode(raw)
+}
+
+// CommentNode implements sml.Builder.
+func (r *positionRecorder) CommentNode(comment string) error {
+	r.record(comment)
+	return r.NodeBuilder.CommentNode(comment)
+}
+
+// record records the content with the current position.
+func (r *positionRecorder) record(content string) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return
+	}
+	r.positions = append(r.positions, fmt.Sprintf("%s %d:%d:%d",
+		content, r.position.Line, r.position.Column, r.position.Offset))
+}
+
+// attributeCollector collects the attributes of the tags.
+type attributeCollector struct {
+	attributes map[string]sml.Attributes
+}
+
+// OpenTag implements sml.Processor.
+func (c *attributeCollector) OpenTag(tag []string) error {
+	return c.OpenTagWithAttributes(tag, nil)
+}
+
+// OpenTagWithAttributes implements sml.AttributeProcessor.
+func (c *attributeCollector) OpenTagWithAttributes(tag []string, attributes sml.Attributes) error {
+	if c.attributes == nil {
+		c.attributes = map[string]sml.Attributes{}
+	}
+	c.attributes[tag[0]] = attributes
+	return nil
+}
+
+// CloseTag implements sml.Processor.
+func (c *attributeCollector) CloseTag(tag []string) error {
+	return nil
+}
+
+// Text implements sml.Processor.
+func (c *attributeCollector) Text(text string) error {
+	return nil
+}
+
+// Raw implements sml.Processor.
+func (c *attributeCollector) Raw(raw string) error {
+	return nil
+}
+
+// Comment implements sml.Processor.
+func (c *attributeCollector) Comment(comment string) error {
+	return nil
+}
+
+// plainNode implements only sml.Node.
+type plainNode struct{}
+
+// Tag implements sml.Node.
+func (n plainNode) Tag() []string {
+	return []string{"plain"}
+}
+
+// Len implements sml.Node.
+func (n plainNode) Len() int {
+	return 0
+}
+
+// ProcessWith implements sml.Node.
+func (n plainNode) ProcessWith(p sml.Processor) error {
+	if err := p.OpenTag(n.Tag()); err != nil {
+		return err
+	}
+	return p.CloseTag(n.Tag())
+}
+
+// String implements sml.Node.
+func (n plainNode) String() string {
+	return "{plain}"
+}
+
 // EOF
@@ -15,6 +15,7 @@ import (
 	"bytes"
 	"encoding/xml"
 	"fmt"
+	"html"
 	"io"
 	"strings"
 
@@ -34,6 +35,16 @@ type WriterProcessor interface {
 	SetContext(ctx *WriterContext)
 }
 
+// LayoutWriterProcessor is a writer processor doing the layout of its
+// output on its own, e.g. for formats where whitespace matters. While it
+// is active neither indentation nor newlines or spaces are written for it.
+type LayoutWriterProcessor interface {
+	WriterProcessor
+
+	// OwnLayout returns true if the processor does its layout.
+	OwnLayout() bool
+}
+
 // WriterProcessors is a map of processors that can be plugged
 // into the used SML writer. The key is the first part of each
 // tag.
@@ -102,9 +113,15 @@ func WriteSML(node Node, ctx *WriterContext) error {
 
 // OpenTag writes the opening of a tag.
 func (w *mlWriter) OpenTag(tag []string) error {
+	return w.OpenTagWithAttributes(tag, nil)
+}
+
+// OpenTagWithAttributes writes the opening of a tag with attributes.
+// Plugins not implementing AttributeProcessor ignore them.
+func (w *mlWriter) OpenTagWithAttributes(tag []string, attributes Attributes) error {
 	w.activatePlugin(tag[0])
 	w.writeIndent(true)
-	if err := w.activePlugin().OpenTag(tag); err != nil {
+	if err := openTag(w.activePlugin(), tag, attributes); err != nil {
 		return err
 	}
 	w.writeNewline()
@@ -115,11 +132,18 @@ func (w *mlWriter) OpenTag(tag []string) error {
 // CloseTag writes the closing of a tag.
 func (w *mlWriter) CloseTag(tag []string) error {
 	w.indent--
-	w.writeIndent(false)
-	if err := w.activePlugin().CloseTag(tag); err != nil {
-		return err
+	if sc, ok := w.activePlugin().(silentCloser); ok && sc.closesSilently(tag) {
+		// Nothing written, so no layout needed.
+		if err := sc.CloseTag(tag); err != nil {
+			return err
+		}
+	} else {
+		w.writeIndent(false)
+		if err := w.activePlugin().CloseTag(tag); err != nil {
+			return err
+		}
+		w.writeNewline()
 	}
-	w.writeNewline()
 	// Check if a plugin is to deactivate.
 	if len(w.stack) > 1 {
 		w.deactivatePlugin()
@@ -158,11 +182,14 @@ func (w *mlWriter) Comment(comment string) error {
 }
 
 // activatePlugin activates a new one of the
-// registered plugins.
+// registered plugins. Otherwise the active one
+// stays active for the tag.
 func (w *mlWriter) activatePlugin(tag string) {
 	if p := w.context.plugins[tag]; p != nil {
 		w.stack = append(w.stack, p)
+		return
 	}
+	w.stack = append(w.stack, w.activePlugin())
 }
 
 // deactivatePlugin deactivates the top plugin.
@@ -175,8 +202,17 @@ func (w *mlWriter) activePlugin() WriterProcessor {
 	return w.stack[len(w.stack)-1]
 }
 
+// ownLayout checks if the active plugin does its layout.
+func (w *mlWriter) ownLayout() bool {
+	lwp, ok := w.activePlugin().(LayoutWriterProcessor)
+	return ok && lwp.OwnLayout()
+}
+
 // writeIndent writes an indentation if wanted.
 func (w *mlWriter) writeIndent(open bool) {
+	if w.ownLayout() {
+		return
+	}
 	if w.context.prettyPrint {
 		for i := 0; i < w.indent; i++ {
 			if err := w.context.Writef(w.context.indentStr); err != nil {
@@ -192,7 +228,7 @@ func (w *mlWriter) writeIndent(open bool) {
 
 // writeNewline writes a newline if wanted.
 func (w *mlWriter) writeNewline() {
-	if w.context.prettyPrint {
+	if w.context.prettyPrint && !w.ownLayout() {
 		if err := w.context.Writef("\n"); err != nil {
 			panic(err)
 		}
@@ -222,7 +258,39 @@ func (w *standardSMLWriter) SetContext(ctx *WriterContext) {
 
 // OpenTag writes the opening of a tag.
 func (w *standardSMLWriter) OpenTag(tag []string) error {
-	return w.context.Writef("{%s", strings.Join(tag, ":"))
+	return w.OpenTagWithAttributes(tag, nil)
+}
+
+// OpenTagWithAttributes writes the opening of a tag followed by
+// the attributes in brackets. Values are quoted.
+func (w *standardSMLWriter) OpenTagWithAttributes(tag []string, attributes Attributes) error {
+	if err := w.context.Writef("{%s", strings.Join(tag, ":")); err != nil {
+		return err
+	}
+	if len(attributes) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	buf.WriteString(" [")
+	for i, a := range attributes {
+		if i > 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(a.Name)
+		if a.Value == "" {
+			continue
+		}
+		buf.WriteString("=\"")
+		for _, r := range a.Value {
+			if r == '^' || r == '"' {
+				buf.WriteRune('^')
+			}
+			buf.WriteRune(r)
+		}
+		buf.WriteString("\"")
+	}
+	buf.WriteString("]")
+	return w.context.Writef("%s", buf.String())
 }
 
 // CloseTag writes the closing of a tag.
@@ -233,6 +301,10 @@ func (w *standardSMLWriter) CloseTag(tag []string) error {
 // Text writes a text with an encoding of special runes.
 func (w *standardSMLWriter) Text(text string) error {
 	var buf bytes.Buffer
+	if strings.HasPrefix(text, "[") {
+		// Don't confuse with attributes.
+		buf.WriteString("^")
+	}
 	for _, r := range text {
 		switch r {
 		case '^':
@@ -283,16 +355,22 @@ func (w *xmlWriter) SetContext(ctx *WriterContext) {
 
 // OpenTag writes the opening of a tag.
 func (w *xmlWriter) OpenTag(tag []string) error {
+	return w.OpenTagWithAttributes(tag, nil)
+}
+
+// OpenTagWithAttributes writes the opening of a tag with the
+// escaped attributes. The second and third part of the tag are
+// written as id and class if not set as attributes.
+func (w *xmlWriter) OpenTagWithAttributes(tag []string, attributes Attributes) error {
 	if err := w.context.Writef("<%s", tag[0]); err != nil {
 		return err
 	}
-	if len(tag) > 1 {
-		if err := w.context.Writef(" id=%q", tag[1]); err != nil {
+	for _, a := range tagAttributes(tag, attributes) {
+		var buf bytes.Buffer
+		if err := xml.EscapeText(&buf, []byte(a.Value)); err != nil {
 			return err
 		}
-	}
-	if len(tag) > 2 {
-		if err := w.context.Writef(" class=%q", tag[2]); err != nil {
+		if err := w.context.Writef(" %s=\"%s\"", a.Name, buf.String()); err != nil {
 			return err
 		}
 	}
@@ -319,4 +397,164 @@ func (w *xmlWriter) Comment(comment string) error {
 	return w.context.Writef("<!-- %s -->", comment)
 }
 
+//--------------------
+// HTML WRITER PROCESSOR
+//--------------------
+
+// htmlVoidElements contains the HTML5 elements having no content
+// and no closing tag.
+var htmlVoidElements = map[string]bool{
+	"area":   true,
+	"base":   true,
+	"br":     true,
+	"col":    true,
+	"embed":  true,
+	"hr":     true,
+	"img":    true,
+	"input":  true,
+	"link":   true,
+	"meta":   true,
+	"param":  true,
+	"source": true,
+	"track":  true,
+	"wbr":    true,
+}
+
+// htmlWriter writes a ML document in HTML5 notation.
+type htmlWriter struct {
+	context *WriterContext
+	depth   int
+	void    string
+}
+
+// NewHTMLWriter creates a new writer for a ML document in HTML5
+// notation. Like with XML the second and third part of the tag are
+// written as id and class. Void elements like br or img have no
+// closing tag and must not have content, raw data is written as
+// escaped content of pre and code. A document starting with the
+// html tag gets a doctype.
+func NewHTMLWriter() WriterProcessor {
+	return &htmlWriter{}
+}
+
+// SetContext sets the writer context.
+func (w *htmlWriter) SetContext(ctx *WriterContext) {
+	w.context = ctx
+}
+
+// OpenTag writes the opening of a tag.
+func (w *htmlWriter) OpenTag(tag []string) error {
+	return w.OpenTagWithAttributes(tag, nil)
+}
+
+// OpenTagWithAttributes writes the opening of a tag with the escaped
+// attributes. Attributes without value are written as boolean ones.
+func (w *htmlWriter) OpenTagWithAttributes(tag []string, attributes Attributes) error {
+	if err := w.checkContent(); err != nil {
+		return err
+	}
+	if w.depth == 0 && tag[0] == "html" {
+		if err := w.context.Writef("<!DOCTYPE html>"); err != nil {
+			return err
+		}
+		if w.context.prettyPrint {
+			if err := w.context.Writef("\n"); err != nil {
+				return err
+			}
+		}
+	}
+	w.depth++
+	if htmlVoidElements[tag[0]] {
+		w.void = tag[0]
+	}
+	if err := w.context.Writef("<%s", tag[0]); err != nil {
+		return err
+	}
+	for _, a := range tagAttributes(tag, attributes) {
+		if a.Value == "" {
+			if err := w.context.Writef(" %s", a.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.context.Writef(" %s=\"%s\"", a.Name, html.EscapeString(a.Value)); err != nil {
+			return err
+		}
+	}
+	return w.context.Writef(">")
+}
+
+// CloseTag writes the closing of a tag, void elements have none.
+func (w *htmlWriter) CloseTag(tag []string) error {
+	w.depth--
+	if w.closesSilently(tag) {
+		w.void = ""
+		return nil
+	}
+	return w.context.Writef("</%s>", tag[0])
+}
+
+// Text writes a text with an encoding of special runes.
+func (w *htmlWriter) Text(text string) error {
+	if err := w.checkContent(); err != nil {
+		return err
+	}
+	return w.context.Writef("%s", html.EscapeString(text))
+}
+
+// Raw writes raw data as preformatted code.
+func (w *htmlWriter) Raw(raw string) error {
+	if err := w.checkContent(); err != nil {
+		return err
+	}
+	return w.context.Writef("<pre><code>%s</code></pre>", html.EscapeString(raw))
+}
+
+// Comment writes comment data without any encoding.
+func (w *htmlWriter) Comment(comment string) error {
+	if err := w.checkContent(); err != nil {
+		return err
+	}
+	return w.context.Writef("<!-- %s -->", comment)
+}
+
+// closesSilently implements silentCloser.
+func (w *htmlWriter) closesSilently(tag []string) bool {
+	return w.void != "" && w.void == tag[0]
+}
+
+// checkContent checks that no void element is open.
+func (w *htmlWriter) checkContent() error {
+	if w.void != "" {
+		return failure.New("void element '%s' cannot have content", w.void)
+	}
+	return nil
+}
+
+//--------------------
+// WRITER HELPERS
+//--------------------
+
+// silentCloser is implemented by processors writing nothing when
+// closing some tags, so that no layout is written for them.
+type silentCloser interface {
+	WriterProcessor
+
+	closesSilently(tag []string) bool
+}
+
+// tagAttributes returns the attributes of a tag for ML formats. The
+// second and third part of the tag are added as id and class if not
+// set as attributes.
+func tagAttributes(tag []string, attributes Attributes) Attributes {
+	all := Attributes{}
+	if _, ok := attributes.Get("id"); len(tag) > 1 && !ok {
+		all = append(all, Attribute{"id", tag[1]})
+	}
+	if _, ok := attributes.Get("class"); len(tag) > 2 && !ok {
+		all = append(all, Attribute{"class", tag[2]})
+	}
+	return append(all, attributes...)
+}
+
 // EOF
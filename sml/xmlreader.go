@@ -0,0 +1,252 @@
+// Tideland Go Text - Simple Markup Language
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package sml // import "tideland.dev/go/text/sml"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// XML READER
+//--------------------
+
+// ReadXML parses a XML document and uses the passed builder for the
+// callbacks, e.g. to convert it into SML. Elements become tag nodes,
+// character data text nodes, CDATA sections raw nodes, and comments
+// comment nodes. Processing instructions, directives, and comments
+// outside of the root element are ignored.
+//
+// Names of elements and attributes are lowercased, '_' and '.' are
+// replaced by '-', and namespace prefixes are dropped. The attributes
+// are mapped like the XML writer does it the other way round:
+//
+//   - id becomes the second part of the tag, class the third one if
+//     id is mapped too, and both are already valid tag parts; as tags
+//     are lowercased values like "Main" stay attributes to keep their
+//     case;
+//   - all others including not mapped id and class are passed to
+//     builders implementing AttributeBuilder;
+//   - other builders get them as tag nodes containing the value as
+//     text at the beginning of the element, so <server port="80"/>
+//     becomes {server {port 80}};
+//   - namespace declarations are dropped.
+//
+// Errors are returned as *ParseError like with ReadSML.
+func ReadXML(reader io.Reader, builder Builder) error {
+	return readXML(reader, builder, true)
+}
+
+// ReadHTML parses a HTML document like ReadXML, but in a non-strict
+// mode. Void elements like br are closed automatically and HTML entities
+// are known. Nevertheless the document has to be mostly well-formed.
+func ReadHTML(reader io.Reader, builder Builder) error {
+	return readXML(reader, builder, false)
+}
+
+// readXML reads the XML document in strict mode or as HTML.
+func readXML(reader io.Reader, builder Builder, strict bool) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return failure.Annotate(err, "cannot read document")
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	if !strict {
+		decoder.Strict = false
+		decoder.AutoClose = xml.HTMLAutoClose
+		decoder.Entity = xml.HTMLEntity
+	}
+	xr := &xmlReader{
+		data:    data,
+		decoder: decoder,
+		builder: builder,
+		pos:     Position{1, 1, 0},
+	}
+	return xr.read()
+}
+
+// xmlReader is used by ReadXML to parse a XML document and
+// pass it to a builder.
+type xmlReader struct {
+	data    []byte
+	decoder *xml.Decoder
+	builder Builder
+	pos     Position
+	depth   int
+	done    bool
+}
+
+// read reads all tokens and passes them to the builder.
+func (xr *xmlReader) read() error {
+	for {
+		start := int(xr.decoder.InputOffset())
+		token, err := xr.decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return xr.parseError(int(xr.decoder.InputOffset()), err)
+		}
+		if err := xr.readToken(start, token); err != nil {
+			return xr.parseError(start, err)
+		}
+	}
+	if !xr.done {
+		return xr.parseError(len(xr.data), failure.New("missing root element"))
+	}
+	return nil
+}
+
+// readToken passes the token starting at the offset to the builder.
+func (xr *xmlReader) readToken(start int, token xml.Token) error {
+	switch t := token.(type) {
+	case xml.StartElement:
+		if xr.done {
+			return failure.New("multiple root elements")
+		}
+		xr.depth++
+		xr.setPosition(start)
+		return xr.beginTagNode(t)
+	case xml.EndElement:
+		xr.depth--
+		if xr.depth == 0 {
+			xr.done = true
+		}
+		return xr.builder.EndTagNode()
+	case xml.CharData:
+		if xr.depth == 0 {
+			if len(bytes.TrimSpace(t)) > 0 {
+				return failure.New("text outside of root element")
+			}
+			return nil
+		}
+		xr.setPosition(start)
+		if bytes.HasPrefix(xr.data[start:], []byte("<![CDATA[")) {
+			return xr.builder.RawNode(string(t))
+		}
+		if len(bytes.TrimSpace(t)) == 0 {
+			return nil
+		}
+		return xr.builder.TextNode(string(t))
+	case xml.Comment:
+		if xr.depth == 0 {
+			return nil
+		}
+		xr.setPosition(start)
+		return xr.builder.CommentNode(string(t))
+	}
+	return nil
+}
+
+// beginTagNode maps the element to a tag and its attributes and
+// begins the tag node.
+func (xr *xmlReader) beginTagNode(element xml.StartElement) error {
+	tag := xmlName(element.Name)
+	attributes := Attributes{}
+	var id, class string
+	for _, attr := range element.Attr {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			continue
+		}
+		name := xmlName(attr.Name)
+		switch {
+		case name == "id" && id == "" && isTagPart(attr.Value):
+			id = attr.Value
+		case name == "class" && class == "" && isTagPart(attr.Value):
+			class = attr.Value
+		default:
+			attributes = append(attributes, Attribute{name, attr.Value})
+		}
+	}
+	if id != "" {
+		tag += ":" + id
+		if class != "" {
+			tag += ":" + class
+		}
+	} else if class != "" {
+		attributes = append(attributes, Attribute{"class", class})
+	}
+	return beginTagNode(xr.builder, tag, attributes)
+}
+
+// setPosition passes the position of the offset to builders
+// interested in it.
+func (xr *xmlReader) setPosition(offset int) {
+	if pb, ok := xr.builder.(PositionBuilder); ok {
+		pb.SetPosition(xr.position(offset))
+	}
+}
+
+// position returns the position of the offset. Offsets are
+// increasing, so the position is moved forward.
+func (xr *xmlReader) position(offset int) Position {
+	if offset > len(xr.data) {
+		offset = len(xr.data)
+	}
+	for xr.pos.Offset < offset {
+		r, size := utf8.DecodeRune(xr.data[xr.pos.Offset:])
+		xr.pos.Offset += size
+		if r == '\n' {
+			xr.pos.Line++
+			xr.pos.Column = 1
+		} else {
+			xr.pos.Column++
+		}
+	}
+	return xr.pos
+}
+
+// parseError wraps the error into a ParseError at the offset.
+func (xr *xmlReader) parseError(offset int, err error) error {
+	pos := xr.position(offset)
+	line := xr.data[:pos.Offset]
+	if i := bytes.LastIndexByte(line, '\n'); i >= 0 {
+		line = line[i+1:]
+	}
+	rs := []rune(string(line))
+	if len(rs) > excerptLen {
+		rs = rs[len(rs)-excerptLen:]
+	}
+	var r rune
+	if pos.Offset < len(xr.data) {
+		r, _ = utf8.DecodeRune(xr.data[pos.Offset:])
+	}
+	return &ParseError{
+		Position: pos,
+		Rune:     r,
+		Excerpt:  string(rs),
+		Err:      err,
+	}
+}
+
+//--------------------
+// XML READER HELPERS
+//--------------------
+
+// xmlName maps a XML name to a SML one.
+func xmlName(name xml.Name) string {
+	return strings.NewReplacer("_", "-", ".", "-").Replace(strings.ToLower(name.Local))
+}
+
+// isTagPart checks if the value can be used unchanged as
+// part of a tag.
+func isTagPart(value string) bool {
+	parts, err := ValidateTag(value)
+	return err == nil && len(parts) == 1 && parts[0] == value
+}
+
+// EOF